@@ -0,0 +1,164 @@
+// Package ast provides a syntax tree for text documents that keeps comments,
+// key order, quoting style and whitespace, so that documents can be edited by
+// a program and written back without disturbing the parts that did not change.
+//
+// An unmodified Document prints back byte-for-byte identical to its source.
+package ast
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/Gophercraft/text"
+)
+
+// Kind identifies the kind of a Node.
+type Kind uint8
+
+const (
+	// A bare or quoted word
+	Word Kind = iota
+	// A bracketed { ... } block
+	Block
+	// A bracketed [ ... ] table header
+	TableHeader
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case Word:
+		return "Word"
+	case Block:
+		return "Block"
+	case TableHeader:
+		return "TableHeader"
+	default:
+		return "Kind(?)"
+	}
+}
+
+// A Node is a word, block or table header.
+// The syntax does not distinguish keys from values: a keyed block is a block
+// whose children alternate between key words and values.
+type Node struct {
+	Kind Kind
+	// Whitespace and comments that precede the node, exactly as in the source
+	Leading string
+
+	// For words, the word with quotes removed and escape sequences resolved.
+	Value string
+	// For words, whether the word is written in quotes
+	Quoted bool
+
+	// For blocks and table headers, the nodes inside the brackets
+	Children []*Node
+	// For blocks and table headers, the whitespace and comments before the closing bracket
+	Closing string
+
+	// The source text of a word, valid as long as Value and Quoted have not been changed
+	source        string
+	source_value  string
+	source_quoted bool
+}
+
+// A Document is a sequence of top-level nodes: usually a single keyed block,
+// or a table header followed by rows.
+type Document struct {
+	Nodes []*Node
+	// Whitespace and comments at the end of the document
+	Trailing string
+}
+
+// NewWord returns a word node. The word is quoted only if necessary.
+func NewWord(value string) *Node {
+	formatted := text.FormatWord(value)
+	return &Node{
+		Kind:   Word,
+		Value:  value,
+		Quoted: strings.HasPrefix(formatted, `"`),
+	}
+}
+
+// NewBlock returns an empty block node.
+func NewBlock() *Node {
+	return &Node{
+		Kind: Block,
+	}
+}
+
+// Returns the text of a word as it should be printed
+func (node *Node) word_text() string {
+	if node.source != "" && node.Value == node.source_value && node.Quoted == node.source_quoted {
+		return node.source
+	}
+
+	if node.Quoted {
+		return text.QuoteWord(node.Value)
+	}
+
+	return text.FormatWord(node.Value)
+}
+
+func (node *Node) print(out *bytes.Buffer) {
+	out.WriteString(node.Leading)
+
+	switch node.Kind {
+	case Word:
+		out.WriteString(node.word_text())
+	case Block, TableHeader:
+		open, close := "{", "}"
+		if node.Kind == TableHeader {
+			open, close = "[", "]"
+		}
+		out.WriteString(open)
+		for _, child := range node.Children {
+			child.print(out)
+		}
+		out.WriteString(node.Closing)
+		out.WriteString(close)
+	}
+}
+
+// String returns the node as text, including its leading whitespace and comments.
+func (node *Node) String() string {
+	var out bytes.Buffer
+	node.print(&out)
+	return out.String()
+}
+
+// Comments returns the comments that precede the node, including their delimiters.
+func (node *Node) Comments() []string {
+	return comments(node.Leading)
+}
+
+// Extracts the comments from whitespace and comments
+func comments(trivia string) (list []string) {
+	decoder := text.NewDecoder(strings.NewReader(trivia))
+	decoder.EmitComments = true
+	for {
+		t, err := decoder.Token()
+		if err != nil || t.Kind != text.TokenComment {
+			return
+		}
+		list = append(list, t.Data)
+	}
+}
+
+// Bytes returns the document as text.
+func (document *Document) Bytes() []byte {
+	var out bytes.Buffer
+	for _, node := range document.Nodes {
+		node.print(&out)
+	}
+	out.WriteString(document.Trailing)
+	return out.Bytes()
+}
+
+// WriteTo writes the document as text to out.
+func (document *Document) WriteTo(out io.Writer) (n int64, err error) {
+	var written int
+	written, err = out.Write(document.Bytes())
+	n = int64(written)
+	return
+}
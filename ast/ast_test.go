@@ -0,0 +1,136 @@
+package ast_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/Gophercraft/text"
+	"github.com/Gophercraft/text/ast"
+)
+
+const config = `// Server configuration
+{
+	/* networking */
+	Listen "0.0.0.0:8085" // public address
+	Realms
+	{
+		{
+			Name   Gophercraft
+			Type   PvP
+		}
+		{ Name "Test Realm" Type PvE }
+	}
+
+	Weights { Fire 1.5  Frost 2 }
+}
+`
+
+func TestRoundTrip(t *testing.T) {
+	for _, source := range []string{config, "", "// only a comment", "[ ID Name ]\n{ 1 a }\n{ 2 \"b c\" } // end"} {
+		document, err := ast.Parse([]byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(document.Bytes()) != source {
+			t.Fatal(string(document.Bytes()), "should have been equal to", source)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	document, err := ast.Parse([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := document.Get("Realms[1].Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Value != "Test Realm" || !node.Quoted {
+		t.Fatal("wrong node", node)
+	}
+
+	listen, err := document.Get("Listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listen.Comments(), []string(nil)) {
+		t.Fatal("unexpected comments", listen.Comments())
+	}
+	if key, _ := document.Get(""); !reflect.DeepEqual(key.Children[0].Comments(), []string{"/* networking */"}) {
+		t.Fatal("wrong comments", key.Children[0].Comments())
+	}
+
+	if err = document.Set("Realms[0].Type", "RP PvP"); err != nil {
+		t.Fatal(err)
+	}
+	if err = document.Set(`Weights["Frost"]`, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err = document.Delete("Listen"); err != nil {
+		t.Fatal(err)
+	}
+	if err = document.Insert("Realms[0]", "Locale", ast.NewWord("enUS")); err != nil {
+		t.Fatal(err)
+	}
+	if err = document.Insert("Weights", "Shadow", ast.NewWord("0.5")); err != nil {
+		t.Fatal(err)
+	}
+	if err = document.Delete("Weights.Fire"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `// Server configuration
+{
+	Realms
+	{
+		{
+			Name   Gophercraft
+			Type   "RP PvP"
+			Locale enUS
+		}
+		{ Name "Test Realm" Type PvE }
+	}
+
+	Weights { Frost 3 Shadow 0.5 }
+}
+`
+	if string(document.Bytes()) != expected {
+		t.Fatal(string(document.Bytes()), "should have been equal to", expected)
+	}
+}
+
+func TestTablePath(t *testing.T) {
+	document, err := ast.Parse([]byte("[ ID Name ]\n{ 1 a }\n{ 2 b }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = document.Set("[1].Name", "Hogger"); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(document.Bytes()) != "[ ID Name ]\n{ 1 a }\n{ 2 Hogger }\n" {
+		t.Fatal(string(document.Bytes()))
+	}
+}
+
+func TestUnclosedBlock(t *testing.T) {
+	_, err := ast.Parse([]byte("{\n\tName Gophercraft\n\tRealms {\n\t\t1 2\n}\n"))
+
+	// The error points at the bracket that is never closed
+	var syntax_error *text.SyntaxError
+	if !errors.As(err, &syntax_error) {
+		t.Fatal("expected a syntax error, got", err)
+	}
+	if syntax_error.Line != 1 || syntax_error.Column != 1 {
+		t.Fatalf("wrong position %d:%d", syntax_error.Line, syntax_error.Column)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("expected an unexpected end of input, got", err)
+	}
+}
@@ -0,0 +1,148 @@
+package ast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Gophercraft/text"
+)
+
+type parser struct {
+	source  []byte
+	decoder *text.Decoder
+	// The end of the last token, where the next node's leading trivia begins
+	offset int64
+}
+
+// Parse parses a text document. Parse returns a *text.SyntaxError if the document is malformed.
+func Parse(source []byte) (document *Document, err error) {
+	p := &parser{
+		source:  source,
+		decoder: text.NewDecoder(bytes.NewReader(source)),
+	}
+
+	document = new(Document)
+
+	for {
+		var node *Node
+		node, err = p.parse_node()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		document.Nodes = append(document.Nodes, node)
+	}
+
+	document.Trailing = string(source[p.offset:])
+	return
+}
+
+// ParseNode parses a single node, such as a word or a block. This is useful for building values to Insert.
+func ParseNode(source string) (node *Node, err error) {
+	var document *Document
+	document, err = Parse([]byte(source))
+	if err != nil {
+		return
+	}
+
+	if len(document.Nodes) != 1 {
+		err = fmt.Errorf("ast: expected exactly one node, found %d", len(document.Nodes))
+		return
+	}
+
+	node = document.Nodes[0]
+	return
+}
+
+// Returns the whitespace and comments preceding t
+func (p *parser) leading(t text.Token) string {
+	leading := string(p.source[p.offset:t.Offset])
+	p.offset = t.End
+	return leading
+}
+
+func (p *parser) syntax_error(t text.Token, format string, args ...any) error {
+	return &text.SyntaxError{
+		Msg:    fmt.Sprintf(format, args...),
+		Line:   t.Line,
+		Column: t.Column,
+		Offset: t.Offset,
+	}
+}
+
+func (p *parser) parse_node() (node *Node, err error) {
+	var t text.Token
+	t, err = p.decoder.Token()
+	if err != nil {
+		return
+	}
+
+	node = &Node{
+		Leading: p.leading(t),
+	}
+
+	switch t.Kind {
+	case text.TokenWord:
+		node.Kind = Word
+		node.Value = t.Data
+		node.Quoted = t.Quoted
+		node.source = string(p.source[t.Offset:t.End])
+		node.source_value = t.Data
+		node.source_quoted = t.Quoted
+	case text.TokenOpen:
+		node.Kind = Block
+		err = p.parse_children(node, t, text.TokenClose)
+	case text.TokenTableHeaderOpen:
+		node.Kind = TableHeader
+		err = p.parse_children(node, t, text.TokenTableHeaderClose)
+	default:
+		err = p.syntax_error(t, "unexpected %s", t.Kind)
+	}
+
+	return
+}
+
+// Parses the children of a block or table header, up to and including the closing bracket.
+// An unclosed node is reported at its opening bracket.
+func (p *parser) parse_children(node *Node, opening text.Token, closing text.TokenKind) (err error) {
+	var t text.Token
+
+	for {
+		t, err = p.decoder.PeekToken()
+		if errors.Is(err, io.EOF) {
+			err = &text.SyntaxError{
+				Msg:    fmt.Sprintf("unexpected end of input in %s", node.Kind),
+				Line:   opening.Line,
+				Column: opening.Column,
+				Offset: opening.Offset,
+				Err:    io.ErrUnexpectedEOF,
+			}
+			return
+		} else if err != nil {
+			return
+		}
+
+		if t.Kind == closing {
+			p.decoder.Token()
+			node.Closing = p.leading(t)
+			return
+		}
+
+		if node.Kind == TableHeader && t.Kind != text.TokenWord {
+			return p.syntax_error(t, "expected a column name in table header, found %s", t.Kind)
+		}
+
+		var child *Node
+		child, err = p.parse_node()
+		if err != nil {
+			return
+		}
+
+		node.Children = append(node.Children, child)
+	}
+}
@@ -0,0 +1,330 @@
+package ast
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// An element of a path
+type path_element struct {
+	name     string
+	index    int
+	is_index bool
+}
+
+func (element path_element) String() string {
+	if element.is_index {
+		return "[" + strconv.Itoa(element.index) + "]"
+	}
+	return strconv.Quote(element.name)
+}
+
+// Parses a path such as Spells[12].Effects.Radius or Names["Fire Ball"]
+func parse_path(path string) (elements []path_element, err error) {
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if strings.HasPrefix(path, `["`) {
+				// find the closing quote, skipping escaped quotes
+				var quoted string
+				quoted, err = strconv.QuotedPrefix(path[1:])
+				if err != nil {
+					return nil, fmt.Errorf("ast: invalid key in path: %w", err)
+				}
+				end = 1 + len(quoted)
+				if end >= len(path) || path[end] != ']' {
+					return nil, fmt.Errorf("ast: expected ] after key in path")
+				}
+				var key string
+				key, _ = strconv.Unquote(quoted)
+				elements = append(elements, path_element{name: key})
+			} else {
+				if end < 0 {
+					return nil, fmt.Errorf("ast: unterminated [ in path")
+				}
+				var index int
+				index, err = strconv.Atoi(path[1:end])
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("ast: invalid index %q in path", path[1:end])
+				}
+				elements = append(elements, path_element{index: index, is_index: true})
+			}
+			path = path[end+1:]
+			continue
+		}
+
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 {
+			continue
+		}
+		elements = append(elements, path_element{name: path[:end]})
+		path = path[end:]
+	}
+	return
+}
+
+// The location of a node within its parent
+type location struct {
+	// The block containing the node, or nil if the node is at the top level of the document
+	parent *Node
+	// The slice containing the node: a block's children or the document's nodes
+	siblings *[]*Node
+	// The index of the node in siblings
+	index int
+	// If the node is a value in a keyed block, the index of its key. Otherwise -1.
+	key int
+}
+
+func (loc location) node() *Node {
+	return (*loc.siblings)[loc.index]
+}
+
+// Finds the value of a key in a keyed block
+func find_key(block *Node, key string) (index int, ok bool) {
+	for i := 0; i+1 < len(block.Children); i += 2 {
+		child := block.Children[i]
+		if child.Kind == Word && child.Value == key {
+			return i, true
+		}
+	}
+	return
+}
+
+// Returns the index of a column in the table header
+func (document *Document) column(name string) (index int, ok bool) {
+	header := document.Nodes[0]
+	for i, column := range header.Children {
+		if column.Value == name {
+			return i, true
+		}
+	}
+	return
+}
+
+// Returns true if the document is a table
+func (document *Document) is_table() bool {
+	return len(document.Nodes) > 0 && document.Nodes[0].Kind == TableHeader
+}
+
+func (document *Document) resolve(path string) (loc location, err error) {
+	var elements []path_element
+	elements, err = parse_path(path)
+	if err != nil {
+		return
+	}
+
+	if len(document.Nodes) == 0 {
+		err = fmt.Errorf("ast: document is empty")
+		return
+	}
+
+	loc = location{siblings: &document.Nodes, key: -1}
+
+	if document.is_table() {
+		// The first element selects a row, and the second a column
+		if len(elements) == 0 || !elements[0].is_index {
+			err = fmt.Errorf("ast: a path in a table must begin with a row index")
+			return
+		}
+		loc.index = elements[0].index + 1
+		if loc.index >= len(document.Nodes) {
+			err = fmt.Errorf("ast: row %d does not exist", elements[0].index)
+			return
+		}
+		elements = elements[1:]
+
+		if len(elements) > 0 && !elements[0].is_index {
+			column, ok := document.column(elements[0].name)
+			if !ok {
+				err = fmt.Errorf("ast: no column named %q", elements[0].name)
+				return
+			}
+			elements[0] = path_element{index: column, is_index: true}
+		}
+	}
+
+	for _, element := range elements {
+		parent := loc.node()
+		if parent.Kind != Block {
+			err = fmt.Errorf("ast: cannot find %v in a %s", element, parent.Kind)
+			return
+		}
+
+		loc = location{parent: parent, siblings: &parent.Children, key: -1}
+
+		if element.is_index {
+			if element.index >= len(parent.Children) {
+				err = fmt.Errorf("ast: index %d out of range", element.index)
+				return
+			}
+			loc.index = element.index
+		} else {
+			key, ok := find_key(parent, element.name)
+			if !ok {
+				err = fmt.Errorf("ast: no key named %q", element.name)
+				return
+			}
+			loc.key = key
+			loc.index = key + 1
+		}
+	}
+
+	return
+}
+
+// Get returns the node at path. A path is made of keys and indices, for example
+// Spells[12].Effects.Radius or Names["Fire Ball"]. The path "" refers to the
+// first node in the document. In a table, the path begins with a row index,
+// optionally followed by a column name.
+func (document *Document) Get(path string) (node *Node, err error) {
+	var loc location
+	loc, err = document.resolve(path)
+	if err != nil {
+		return
+	}
+
+	node = loc.node()
+	return
+}
+
+// Set changes the value of the word at path. The word keeps its quoting style where possible.
+func (document *Document) Set(path string, value string) (err error) {
+	var node *Node
+	node, err = document.Get(path)
+	if err != nil {
+		return
+	}
+
+	if node.Kind != Word {
+		return fmt.Errorf("ast: %s is a %s, not a word", path, node.Kind)
+	}
+
+	node.Value = value
+	if !node.Quoted {
+		node.Quoted = NewWord(value).Quoted
+	}
+	return
+}
+
+// Replace replaces the node at path. The replacement takes the place (and leading whitespace) of the old node.
+func (document *Document) Replace(path string, replacement *Node) (err error) {
+	var loc location
+	loc, err = document.resolve(path)
+	if err != nil {
+		return
+	}
+
+	replacement.Leading = loc.node().Leading
+	(*loc.siblings)[loc.index] = replacement
+	return
+}
+
+// Delete removes the node at path. If the node is a value in a keyed block, its key is removed too.
+func (document *Document) Delete(path string) (err error) {
+	var loc location
+	loc, err = document.resolve(path)
+	if err != nil {
+		return
+	}
+
+	first := loc.index
+	if loc.key >= 0 {
+		first = loc.key
+	}
+
+	// The whitespace that follows the removed nodes: the leading of the next sibling, or the closing of the parent
+	var following *string
+	if loc.index+1 < len(*loc.siblings) {
+		following = &(*loc.siblings)[loc.index+1].Leading
+	} else if loc.parent != nil {
+		following = &loc.parent.Closing
+	} else {
+		following = &document.Trailing
+	}
+
+	// The rest of the removed entry's last line (for example, a trailing comment) is removed with it.
+	// Whatever was on the line before the removed entry is kept.
+	removed_leading := (*loc.siblings)[first].Leading
+	if i := strings.IndexByte(*following, '\n'); i >= 0 {
+		before := removed_leading
+		if j := strings.IndexByte(removed_leading, '\n'); j >= 0 {
+			before = removed_leading[:j]
+		}
+		*following = before + (*following)[i:]
+	} else {
+		*following = removed_leading
+	}
+
+	*loc.siblings = slices.Delete(*loc.siblings, first, loc.index+1)
+	return
+}
+
+// Returns the whitespace that should precede a new entry of a block
+func entry_leading(block *Node, keyed bool) string {
+	// Follow the example of the last entry
+	last := len(block.Children) - 1
+	if keyed {
+		last--
+	}
+
+	if last >= 0 {
+		leading := block.Children[last].Leading
+		if i := strings.LastIndexByte(leading, '\n'); i >= 0 {
+			return "\n" + leading[i+1:]
+		}
+		return " "
+	}
+
+	// An empty block: indent one level deeper than the closing bracket
+	if i := strings.LastIndexByte(block.Closing, '\n'); i >= 0 {
+		return "\n" + block.Closing[i+1:] + "\t"
+	}
+
+	return " "
+}
+
+// Insert adds an entry to the end of the block at path. If key is not empty,
+// the entry is a key followed by value, otherwise value is added as an element.
+func (document *Document) Insert(path string, key string, value *Node) (err error) {
+	var block *Node
+	block, err = document.Get(path)
+	if err != nil {
+		return
+	}
+
+	if block.Kind != Block {
+		return fmt.Errorf("ast: %s is a %s, not a block", path, block.Kind)
+	}
+
+	was_empty := len(block.Children) == 0
+	leading := entry_leading(block, key != "")
+
+	if key != "" {
+		key_node := NewWord(key)
+		key_node.Leading = leading
+		block.Children = append(block.Children, key_node)
+
+		if value.Kind == Word || !strings.HasPrefix(leading, "\n") {
+			value.Leading = " "
+		} else {
+			value.Leading = leading
+		}
+	} else {
+		value.Leading = leading
+	}
+
+	block.Children = append(block.Children, value)
+
+	if was_empty && block.Closing == "" {
+		block.Closing = " "
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type bench_item struct {
+	ID       uint32
+	Name     string
+	Quality  uint8
+	Price    int64
+	Weight   float32
+	Stats    []int32
+	Disabled bool
+}
+
+func bench_items(n int) []bench_item {
+	items := make([]bench_item, n)
+	for i := range items {
+		items[i] = bench_item{
+			ID:      uint32(i + 1),
+			Name:    "Item " + strconv.Itoa(i),
+			Quality: uint8(i % 6),
+			Price:   int64(i * 25),
+			Weight:  float32(i) / 4,
+			Stats:   []int32{int32(i), 7, -3},
+		}
+	}
+	return items
+}
+
+func bench_table(b *testing.B, n int) []byte {
+	var out bytes.Buffer
+	encoder := text.NewEncoder(&out)
+	encoder.Tabular = true
+	encoder.Indent = " "
+	for _, item := range bench_items(n) {
+		if err := encoder.Encode(&item); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func BenchmarkDecodeTable(b *testing.B) {
+	data := bench_table(b, 1000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		decoder := text.NewDecoder(bytes.NewReader(data))
+		for {
+			var item bench_item
+			err := decoder.Decode(&item)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeTableParallel(b *testing.B) {
+	data := bench_table(b, 1000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := text.DecodeTableParallel[bench_item](bytes.NewReader(data), text.ParallelOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeKeyed(b *testing.B) {
+	data, err := text.Marshal(bench_items(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		var items []bench_item
+		if err := text.Unmarshal(data, &items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeKeyed(b *testing.B) {
+	items := bench_items(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		var out strings.Builder
+		encoder := text.NewEncoder(&out)
+		if err := encoder.Encode(items); err != nil {
+			b.Fatal(err)
+		}
+		if err := encoder.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Decoding into a RawValue only scans tokens, so this measures the tokenizer on its own
+func BenchmarkTokenizer(b *testing.B) {
+	data, err := text.Marshal(bench_items(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var tokens int
+	decoder := text.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err = decoder.Token(); err != nil {
+			break
+		}
+		tokens++
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		var raw text.RawValue
+		if err := text.Unmarshal(data, &raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*tokens), "ns/token")
+}
@@ -0,0 +1,243 @@
+package text
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is implemented by types that encode themselves as an entire bracketed block,
+// for example types whose layout varies and cannot be expressed as a plain struct.
+//
+// MarshalBlock is called after the opening bracket has been written, and should write
+// the contents of the block using the BlockEncoder. The closing bracket is written afterwards.
+type Marshaler interface {
+	MarshalBlock(block *BlockEncoder) error
+}
+
+// Unmarshaler is implemented by types that decode themselves from an entire bracketed block.
+//
+// UnmarshalBlock is called after the opening bracket has been consumed, and must consume
+// the contents of the block using the BlockDecoder, leaving only the closing bracket.
+type Unmarshaler interface {
+	UnmarshalBlock(block *BlockDecoder) error
+}
+
+var (
+	marshaler_type   = reflect.TypeFor[Marshaler]()
+	unmarshaler_type = reflect.TypeFor[Unmarshaler]()
+)
+
+func can_marshal_block(value reflect.Value) bool {
+	return get_type_info(value.Type()).marshaler
+}
+
+func can_unmarshal_block(value reflect.Value) bool {
+	return get_type_info(value.Type()).unmarshaler
+}
+
+// A BlockEncoder writes the contents of a block for a Marshaler.
+// The block is written in the same notation as its surroundings: one entry per line in keyed
+// documents, or on a single line in a table row.
+type BlockEncoder struct {
+	encoder *Encoder
+	// The depth of the block being written
+	depth int
+	// Set if the block is inside a table row
+	column bool
+	// Set once something has been written into a column block
+	written bool
+}
+
+// EncodeEntry writes a key followed by its value, like a struct field or map entry.
+func (block *BlockEncoder) EncodeEntry(key string, value any) (err error) {
+	encoder := block.encoder
+
+	if block.column {
+		block.separate()
+		if err = encoder.encode_string(key); err != nil {
+			return
+		}
+		encoder.out.Write([]byte(" "))
+		return encoder.encode_column(block_value(value))
+	}
+
+	encoder.writeIndentation(block.depth + 1)
+	if err = encoder.encode_string(key); err != nil {
+		return
+	}
+	return encoder.encode_keyed_value(block.depth+1, block_value(value))
+}
+
+// EncodeElement writes a value without a key, like an element of a slice.
+func (block *BlockEncoder) EncodeElement(value any) (err error) {
+	if block.column {
+		block.separate()
+		return block.encoder.encode_column(block_value(value))
+	}
+
+	return block.encoder.encode_element(block.depth+1, block_value(value))
+}
+
+// Returns the value to encode for an entry or element. A nil value is encoded as a nil interface.
+func block_value(value any) reflect.Value {
+	if value == nil {
+		return reflect.ValueOf(&value).Elem()
+	}
+	return reflect.ValueOf(value)
+}
+
+// Writes the space between elements of a column block
+func (block *BlockEncoder) separate() {
+	block.encoder.out.Write([]byte(" "))
+	block.written = true
+}
+
+// Returns the Marshaler implemented by a value
+func get_marshaler(value reflect.Value) Marshaler {
+	if value.Type().Implements(marshaler_type) {
+		return value.Interface().(Marshaler)
+	}
+	return addressable(value).Interface().(Marshaler)
+}
+
+func (encoder *Encoder) encode_block(depth int, value reflect.Value) (err error) {
+	block := &BlockEncoder{
+		encoder: encoder,
+		depth:   depth,
+	}
+
+	encoder.out.Write([]byte("{\n"))
+	if err = get_marshaler(value).MarshalBlock(block); err != nil {
+		return
+	}
+	encoder.writeIndentation(depth)
+	_, err = encoder.out.Write([]byte("}\n"))
+	return
+}
+
+func (encoder *Encoder) encode_block_column(value reflect.Value) (err error) {
+	block := &BlockEncoder{
+		encoder: encoder,
+		column:  true,
+	}
+
+	encoder.out.Write([]byte("{"))
+	if err = get_marshaler(value).MarshalBlock(block); err != nil {
+		return
+	}
+	if block.written {
+		encoder.out.Write([]byte(" "))
+	}
+	_, err = encoder.out.Write([]byte("}"))
+	return
+}
+
+// A BlockDecoder reads the contents of a block for an Unmarshaler.
+type BlockDecoder struct {
+	decoder *Decoder
+	// Set if the block is inside a table row
+	column bool
+	// The number of entries and elements decoded, used to locate errors
+	count int
+}
+
+// More reports whether there is another token before the end of the block.
+func (block *BlockDecoder) More() bool {
+	t, err := block.decoder.peek_token()
+	return err == nil && t.Type != token_close
+}
+
+// PeekToken returns the next token in the block without consuming it.
+func (block *BlockDecoder) PeekToken() (Token, error) {
+	t, err := block.decoder.peek_token()
+	if err != nil {
+		return Token{}, err
+	}
+	return t.export(), nil
+}
+
+// DecodeKey consumes a word, such as the key of an entry.
+func (block *BlockDecoder) DecodeKey() (key string, err error) {
+	var t token
+	t, err = block.decoder.next_word()
+	if err != nil {
+		return
+	}
+	key = string(t.Data)
+	return
+}
+
+// DecodeEntry consumes a key and decodes the value that follows it into value.
+func (block *BlockDecoder) DecodeEntry(value any) (key string, err error) {
+	key, err = block.DecodeKey()
+	if err != nil {
+		return
+	}
+
+	block.decoder.push_field(key)
+	if err = block.decode(value); err != nil {
+		return
+	}
+	block.decoder.pop_path()
+	return
+}
+
+// DecodeElement decodes the next value in the block into the value pointed to by value.
+func (block *BlockDecoder) DecodeElement(value any) (err error) {
+	block.decoder.push_index(block.count)
+	if err = block.decode(value); err != nil {
+		return
+	}
+	block.decoder.pop_path()
+	block.count++
+	return
+}
+
+func (block *BlockDecoder) decode(value any) (err error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("text: cannot decode into non-pointer %T", value)
+	}
+
+	if block.column {
+		return block.decoder.decode_column(v.Elem())
+	}
+	return block.decoder.decode_value(v.Elem())
+}
+
+// Returns the Unmarshaler implemented by a value
+func get_unmarshaler(value reflect.Value) Unmarshaler {
+	if reflect.PointerTo(value.Type()).Implements(unmarshaler_type) {
+		return value.Addr().Interface().(Unmarshaler)
+	}
+	return value.Interface().(Unmarshaler)
+}
+
+func (decoder *Decoder) decode_block(value reflect.Value, column bool) (err error) {
+	_, err = decoder.expect_token(token_open, "at start of block")
+	if err != nil {
+		return
+	}
+
+	initialize_value(value)
+
+	block := &BlockDecoder{
+		decoder: decoder,
+		column:  column,
+	}
+
+	if err = get_unmarshaler(value).UnmarshalBlock(block); err != nil {
+		return
+	}
+
+	var close_token token
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of %s, found %s", value.Type(), close_token)
+	}
+	return
+}
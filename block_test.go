@@ -0,0 +1,184 @@
+package text_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+// A loot entry is either an item with a count, or a list of currencies
+type loot_entry struct {
+	Item       uint32
+	Count      uint32
+	Currencies []string
+}
+
+func (entry *loot_entry) MarshalBlock(block *text.BlockEncoder) error {
+	if entry.Item != 0 {
+		if err := block.EncodeEntry("Item", entry.Item); err != nil {
+			return err
+		}
+		return block.EncodeEntry("Count", entry.Count)
+	}
+
+	for _, currency := range entry.Currencies {
+		if err := block.EncodeElement(currency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (entry *loot_entry) UnmarshalBlock(block *text.BlockDecoder) error {
+	token, err := block.PeekToken()
+	if err != nil {
+		return err
+	}
+
+	if token.Kind == text.TokenWord && token.Data == "Item" {
+		for block.More() {
+			var value uint32
+			key, err := block.DecodeEntry(&value)
+			if err != nil {
+				return err
+			}
+			switch key {
+			case "Item":
+				entry.Item = value
+			case "Count":
+				entry.Count = value
+			default:
+				return fmt.Errorf("unknown key %s", key)
+			}
+		}
+		return nil
+	}
+
+	for block.More() {
+		var currency string
+		if err := block.DecodeElement(&currency); err != nil {
+			return err
+		}
+		entry.Currencies = append(entry.Currencies, currency)
+	}
+	return nil
+}
+
+type loot_table struct {
+	ID      uint32
+	Entries []loot_entry
+}
+
+var loot = loot_table{
+	ID: 1,
+	Entries: []loot_entry{
+		{Item: 2589, Count: 3},
+		{Currencies: []string{"gold", "honor"}},
+		{},
+	},
+}
+
+func TestBlockMarshaler(t *testing.T) {
+	data, err := text.Marshal(&loot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+	ID 1
+	Entries
+	{
+		{
+			Item 2589
+			Count 3
+		}
+		{
+			gold
+			honor
+		}
+		{
+		}
+	}
+}
+`
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+
+	var decoded loot_table
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loot, decoded) {
+		t.Fatal("got back incorrect loot table", decoded)
+	}
+}
+
+func TestBlockMarshalerTable(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+	encoder.Tabular = true
+
+	if err := encoder.Encode(&loot); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[ ID Entries ]\n{ 1 { { Item 2589 Count 3 } { gold honor } {} } }\n"
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	var decoded loot_table
+	if err := text.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loot, decoded) {
+		t.Fatal("got back incorrect loot table", decoded)
+	}
+}
+
+// Writes nil as an entry and as an element
+type nil_block struct {
+	Count uint32
+}
+
+func (nil_block) MarshalBlock(block *text.BlockEncoder) error {
+	if err := block.EncodeEntry("Icon", nil); err != nil {
+		return err
+	}
+	return block.EncodeElement(nil)
+}
+
+type nil_block_record struct {
+	ID    uint32
+	Block nil_block
+}
+
+func TestBlockMarshalerNil(t *testing.T) {
+	data, err := text.Marshal(&nil_block_record{ID: 1, Block: nil_block{Count: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\n\tID 1\n\tBlock\n\t{\n\t\tIcon nil\n\t\tnil\n\t}\n}\n"
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+
+	var buf bytes.Buffer
+	if err = text.EncodeAll(&buf, []nil_block_record{{ID: 1, Block: nil_block{Count: 1}}}, text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "[ ID Block ]\n{ 1 { Icon nil nil } }\n"
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+}
@@ -0,0 +1,123 @@
+package text
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// What is known about a Go type, worked out once and shared by all Encoders and Decoders
+type type_info struct {
+	word        bool
+	marshaler   bool
+	unmarshaler bool
+	// The fields of a struct type
+	fields *struct_fields
+}
+
+// Maps reflect.Type to *type_info
+var type_cache sync.Map
+
+// Returns the cached information about a type
+func get_type_info(t reflect.Type) *type_info {
+	if info, ok := type_cache.Load(t); ok {
+		return info.(*type_info)
+	}
+
+	info := &type_info{
+		word:        is_word(t),
+		marshaler:   implements(t, marshaler_type),
+		unmarshaler: implements(t, unmarshaler_type),
+	}
+	if t.Kind() == reflect.Struct {
+		info.fields = build_struct_fields(t)
+	}
+
+	// Another goroutine may have got there first
+	actual, _ := type_cache.LoadOrStore(t, info)
+	return actual.(*type_info)
+}
+
+// The struct fields that the columns of a table header refer to, for one row type
+type row_plan struct {
+	row_type reflect.Type
+	fields   *struct_fields
+	// The field for each column, or nil if the column is unknown
+	columns []*field
+	// Columns left out by SelectColumns, which are skipped
+	skip []bool
+	// Fields with no column, which are set to their default values in each row
+	defaults []row_default
+	// Fields with no column and no default value
+	missing []*field
+}
+
+// Returns the plan for decoding rows of a type under the current table header
+func (decoder *Decoder) get_row_plan(t reflect.Type) (plan *row_plan, err error) {
+	if decoder.plan != nil && decoder.plan.row_type == t {
+		return decoder.plan, nil
+	}
+
+	var fields *struct_fields
+	if fields, err = get_struct_fields(t); err != nil {
+		return
+	}
+
+	plan = &row_plan{
+		row_type: t,
+		fields:   fields,
+		columns:  make([]*field, len(decoder.columns)),
+		skip:     make([]bool, len(decoder.columns)),
+	}
+
+	// The column that each field is decoded from
+	column_of := make([]int, len(plan.fields.list))
+	for i := range column_of {
+		column_of[i] = -1
+	}
+
+	for i, name := range decoder.columns {
+		index, ok := plan.fields.by_name[name]
+		if ok {
+			if column := column_of[index]; column >= 0 {
+				return nil, fmt.Errorf("text: columns %s and %s are both decoded into field %s", decoder.columns[column], name, plan.fields.list[index].name)
+			}
+			column_of[index] = i
+		}
+
+		if decoder.selected_columns != nil && !slices.Contains(decoder.selected_columns, name) {
+			plan.skip[i] = true
+			continue
+		}
+		if ok {
+			plan.columns[i] = &plan.fields.list[index]
+			if err = check_column_type(t, plan.columns[i], name, decoder.column_types[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, column := range column_of {
+		if column >= 0 {
+			continue
+		}
+
+		f := &plan.fields.list[i]
+		switch {
+		case f.has_default:
+			var d row_default
+			if d, err = new_row_default(t, f); err != nil {
+				return nil, err
+			}
+			plan.defaults = append(plan.defaults, d)
+		case f.required:
+			return nil, fmt.Errorf("text: table has no column for required field %s", f.name)
+		default:
+			plan.missing = append(plan.missing, f)
+		}
+	}
+
+	decoder.plan = plan
+	return
+}
@@ -0,0 +1,229 @@
+// Package dbc reads and writes the binary client database files of World of Warcraft,
+// and converts them to and from tabular text documents.
+//
+// A record is described by a struct whose fields are stored in the order they are declared:
+//
+//	type Spell struct {
+//		ID       uint32
+//		Name     [8]string      // each string is an offset into the string block
+//		Category uint8
+//		_        [3]byte        // blank fields are padding
+//		Position struct{ X, Y, Z float32 }
+//		Ranks    []int32  `dbc:"len=4"`  // slices are stored like arrays of the given length
+//		Flags    uint32   `dbc:"size=1"` // stored in 1 byte
+//		Comment  string   `dbc:"-"`      // not stored in the file
+//	}
+//
+// Integers and floats take their own size unless a size is given, bools take 4 bytes,
+// and arrays and structs are stored element by element.
+//
+// In DB2 files from WDB5 onwards, each field's size and position is stored in the file,
+// and may be bit-packed or compressed. A field there is a value or an array of values,
+// so a struct such as Position above holds three fields, while a [3]float32 would be one.
+// The ID of each record may be stored outside of it, as may a foreign key in the
+// relationship data of WDC files; tag fields with `dbc:"id"` and `dbc:"relation"` to
+// receive them. Records with an offset map store their strings inline, and their string
+// fields must be tagged `dbc:"inline"`.
+//
+// Read supports WDBC, WDB2 to WDB6, and WDC1 to WDC3 files. Sections of WDC2 and WDC3
+// files that are still encrypted are skipped. Write supports WDBC and WDB2 files.
+package dbc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/Gophercraft/text"
+)
+
+// ErrUnsupportedFormat is returned for client database formats that this package cannot read or write.
+var ErrUnsupportedFormat = errors.New("dbc: unsupported format")
+
+// The sizes of the file headers
+const (
+	wdbc_header_size = 20
+	wdb2_header_size = 48
+)
+
+// A Header describes a client database file.
+type Header struct {
+	// The format of the file, such as "WDBC", "WDB2" or "WDC3"
+	Magic           string
+	RecordCount     uint32
+	FieldCount      uint32
+	RecordSize      uint32
+	StringBlockSize uint32
+	// The remaining fields are not stored in WDBC files
+	TableHash uint32
+	// Build and Timestamp are replaced by LayoutHash from WDB5 onwards
+	Build         uint32
+	Timestamp     uint32
+	LayoutHash    uint32
+	MinID         uint32
+	MaxID         uint32
+	Locale        uint32
+	CopyTableSize uint32
+	// Flags are stored from WDB4 onwards, and IDIndex from WDB5 onwards
+	Flags   uint32
+	IDIndex uint16
+	// From WDB6 onwards, the number of fields including those not stored in the records
+	TotalFieldCount uint32
+}
+
+// Strings stored at offsets in a file, each ending with a zero byte
+type string_block struct {
+	data    []byte
+	offsets map[string]uint32
+}
+
+func (block *string_block) lookup(offset int64) (str string, err error) {
+	if offset < 0 || offset >= int64(len(block.data)) {
+		return "", fmt.Errorf("dbc: string offset %d is outside of the string block", offset)
+	}
+
+	str = string(block.data[offset:])
+	if end := bytes.IndexByte(block.data[offset:], 0); end >= 0 {
+		str = str[:end]
+	}
+	return
+}
+
+// Returns the offset of a string, adding it to the block if it is not there yet
+func (block *string_block) add(str string) uint32 {
+	if offset, ok := block.offsets[str]; ok {
+		return offset
+	}
+
+	offset := uint32(len(block.data))
+	block.data = append(block.data, str...)
+	block.data = append(block.data, 0)
+	block.offsets[str] = offset
+	return offset
+}
+
+func new_string_block() *string_block {
+	// Offset 0 is the empty string
+	return &string_block{
+		data:    []byte{0},
+		offsets: map[string]uint32{"": 0},
+	}
+}
+
+// Read reads a client database file into records of type T.
+func Read[T any](r io.Reader) (records []T, header Header, err error) {
+	var data []byte
+	if data, err = io.ReadAll(r); err != nil {
+		return
+	}
+
+	var l *layout
+	if l, err = get_layout(reflect.TypeFor[T]()); err != nil {
+		return
+	}
+
+	var t *table
+	t, err = parse(data, l)
+	if t != nil {
+		header = t.header
+	}
+	if err != nil {
+		return
+	}
+
+	if err = t.check(l); err != nil {
+		return
+	}
+	if err = t.find_ids(); err != nil {
+		return
+	}
+	t.copy_records()
+
+	records = make([]T, len(t.records))
+	for i := range records {
+		if err = t.decode(l, &t.records[i], reflect.ValueOf(&records[i]).Elem()); err != nil {
+			err = fmt.Errorf("%w in record %d", err, i)
+			return
+		}
+	}
+	return
+}
+
+// Write writes records as a client database file in the format named by header.Magic,
+// or as a WDBC file if it is empty. The counts and sizes in header are worked out from
+// the records. WDB2 files are written without an ID index, so MinID and MaxID are zero.
+// Only WDBC and WDB2 files can be written, and fields tagged id or relation are not stored.
+func Write[T any](w io.Writer, records []T, header Header) (err error) {
+	var l *layout
+	if l, err = get_layout(reflect.TypeFor[T]()); err != nil {
+		return
+	}
+
+	header_size := wdbc_header_size
+	switch header.Magic {
+	case "", "WDBC":
+		header.Magic = "WDBC"
+	case "WDB2":
+		header_size = wdb2_header_size
+		header.MinID = 0
+		header.MaxID = 0
+	default:
+		return fmt.Errorf("%w %q", ErrUnsupportedFormat, header.Magic)
+	}
+
+	if l.inline {
+		return fmt.Errorf("dbc: %s files cannot store inline strings", header.Magic)
+	}
+
+	block := new_string_block()
+	data := make([]byte, header_size, header_size+len(records)*l.size)
+	for i := range records {
+		data = l.append(data, block, reflect.ValueOf(&records[i]).Elem())
+	}
+
+	header.RecordCount = uint32(len(records))
+	header.FieldCount = uint32(l.values)
+	header.RecordSize = uint32(l.size)
+	header.StringBlockSize = uint32(len(block.data))
+
+	copy(data, header.Magic)
+	fields := []uint32{
+		header.RecordCount, header.FieldCount, header.RecordSize, header.StringBlockSize,
+		header.TableHash, header.Build, header.Timestamp, header.MinID, header.MaxID, header.Locale, header.CopyTableSize,
+	}
+	for i := range (header_size - 4) / 4 {
+		binary.LittleEndian.PutUint32(data[4+4*i:], fields[i])
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return
+	}
+	_, err = w.Write(block.data)
+	return
+}
+
+// Import reads a client database file from r and writes its records to w as a tabular text document.
+// The file's header is returned, so that it can be given to Export later.
+func Import[T any](w io.Writer, r io.Reader, opts text.EncodeOptions) (header Header, err error) {
+	var records []T
+	if records, header, err = Read[T](r); err != nil {
+		return
+	}
+
+	opts.Tabular = true
+	err = text.EncodeAll(w, records, opts)
+	return
+}
+
+// Export reads the rows of a tabular text document from r and writes them to w as a client database file,
+// described by header as in Write.
+func Export[T any](w io.Writer, r io.Reader, header Header) (err error) {
+	var records []T
+	if records, err = text.DecodeAll[T](r); err != nil {
+		return
+	}
+	return Write(w, records, header)
+}
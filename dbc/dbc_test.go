@@ -0,0 +1,555 @@
+package dbc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+	"github.com/Gophercraft/text/dbc"
+)
+
+type vector3 struct {
+	X, Y, Z float32
+}
+
+type spell struct {
+	ID       uint32
+	Name     [2]string
+	School   uint8
+	_        [1]byte
+	Level    int16
+	Position vector3
+	Passive  bool
+	Comment  string `dbc:"-"`
+}
+
+var spells = []spell{
+	{ID: 133, Name: [2]string{"Fireball", "Boule de feu"}, School: 2, Level: 1, Position: vector3{1, 2, 3}, Passive: true},
+	{ID: 116, Name: [2]string{"Frostbolt", ""}, School: 4, Level: -4},
+	{ID: 118, Name: [2]string{"Polymorph", "Fireball"}, School: 6, Level: 8},
+}
+
+func TestReadWrite(t *testing.T) {
+	for _, magic := range []string{"WDBC", "WDB2"} {
+		var buf bytes.Buffer
+		if err := dbc.Write(&buf, spells, dbc.Header{Magic: magic, Build: 12340}); err != nil {
+			t.Fatal(err)
+		}
+
+		records, header, err := dbc.Read[spell](bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(records, spells) {
+			t.Fatal("got back incorrect records", records)
+		}
+
+		// Strings are stored once each, after an empty string
+		expected := dbc.Header{
+			Magic:           magic,
+			RecordCount:     3,
+			FieldCount:      9,
+			RecordSize:      32,
+			StringBlockSize: uint32(len("\x00Fireball\x00Boule de feu\x00Frostbolt\x00Polymorph\x00")),
+		}
+		if magic == "WDB2" {
+			expected.Build = 12340
+		}
+		if header != expected {
+			t.Fatalf("wrong header %+v", header)
+		}
+	}
+}
+
+func TestReadIDIndex(t *testing.T) {
+	var buf bytes.Buffer
+	if err := dbc.Write(&buf, spells, dbc.Header{Magic: "WDB2", Build: 15595}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files from later builds have an index of IDs after the header
+	data := buf.Bytes()
+	header := bytes.Clone(data[:48])
+	binary.LittleEndian.PutUint32(header[32:], 116)
+	binary.LittleEndian.PutUint32(header[36:], 133)
+	index := make([]byte, (133-116+1)*6)
+	data = append(append(header, index...), data[48:]...)
+
+	records, _, err := dbc.Read[spell](bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, spells) {
+		t.Fatal("got back incorrect records", records)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := dbc.Write(&buf, spells, dbc.Header{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := dbc.Read[spell](bytes.NewReader([]byte("WDC5\x00\x00\x00\x00"))); !errors.Is(err, dbc.ErrUnsupportedFormat) {
+		t.Fatal("expected an unsupported format, got", err)
+	}
+
+	if _, _, err := dbc.Read[spell](bytes.NewReader([]byte("WDC3\x00\x00\x00\x00"))); err == nil {
+		t.Fatal("expected an error for a truncated WDC3 file")
+	}
+
+	// Records of the right size, with the wrong number of fields
+	if _, _, err := dbc.Read[struct{ A [4]uint64 }](bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("expected an error for a record with the wrong number of fields")
+	}
+
+	if _, _, err := dbc.Read[vector3](bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("expected an error for a record of the wrong size")
+	}
+
+	if _, _, err := dbc.Read[spell](bytes.NewReader(buf.Bytes()[:buf.Len()-10])); err == nil {
+		t.Fatal("expected an error for a truncated file")
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	var binary_file bytes.Buffer
+	if err := dbc.Write(&binary_file, spells, dbc.Header{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var document bytes.Buffer
+	header, err := dbc.Import[spell](&document, bytes.NewReader(binary_file.Bytes()), text.EncodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[ ID Name School Level Position Passive Comment ]
+{ 133 { Fireball "Boule de feu" } 2 1 { 1 2 3 } true "" }
+{ 116 { Frostbolt "" } 4 -4 {} false "" }
+{ 118 { Polymorph Fireball } 6 8 {} false "" }
+`
+	if document.String() != expected {
+		t.Fatal(document.String(), "should have been equal to", expected)
+	}
+
+	var exported bytes.Buffer
+	if err = dbc.Export[spell](&exported, &document, header); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exported.Bytes(), binary_file.Bytes()) {
+		t.Fatal("exported file is different from the original")
+	}
+}
+
+type tagged struct {
+	ID    uint32
+	Flags uint32  `dbc:"size=1"`
+	Costs []int16 `dbc:"len=2"`
+	Level int32   `dbc:"size=3"`
+	Note  string  `dbc:"-"`
+}
+
+func TestTags(t *testing.T) {
+	records := []tagged{
+		{ID: 1, Flags: 0x80, Costs: []int16{-1, 2}, Level: -5},
+		{ID: 2, Flags: 3, Costs: []int16{5}, Level: 70},
+	}
+
+	var buf bytes.Buffer
+	if err := dbc.Write(&buf, records, dbc.Header{}); err != nil {
+		t.Fatal(err)
+	}
+
+	read, header, err := dbc.Read[tagged](bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Slices are read back with their full length
+	records[1].Costs = []int16{5, 0}
+	if !reflect.DeepEqual(read, records) {
+		t.Fatal("got back incorrect records", read)
+	}
+	if header.RecordSize != 12 || header.FieldCount != 5 {
+		t.Fatalf("wrong header %+v", header)
+	}
+
+	if err := dbc.Write(&buf, []struct{ Costs []int16 }{{}}, dbc.Header{}); err == nil {
+		t.Fatal("expected an error for a slice without a length")
+	}
+	if err := dbc.Write(&buf, []struct {
+		Level int32 `dbc:"bits=3"`
+	}{{}}, dbc.Header{}); err == nil {
+		t.Fatal("expected an error for an unknown tag option")
+	}
+	if err := dbc.Write(&buf, []struct {
+		Name string `dbc:"inline"`
+	}{{}}, dbc.Header{}); err == nil {
+		t.Fatal("expected an error for an inline string in a WDBC file")
+	}
+}
+
+// Joins little-endian values into the bytes of a file
+func le(values ...any) []byte {
+	var buf bytes.Buffer
+	for _, value := range values {
+		if err := binary.Write(&buf, binary.LittleEndian, value); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Sets size bits of a record, beginning at a bit offset
+func put_bits(record []byte, offset, size int, value uint64) {
+	for i := range size {
+		if value>>i&1 != 0 {
+			record[(offset+i)/8] |= 1 << ((offset + i) % 8)
+		}
+	}
+}
+
+type creature struct {
+	ID     uint32
+	Name   string
+	Level  int32
+	Scale  [2]float32
+	Family uint8
+}
+
+type ranked_creature struct {
+	ID     uint32
+	Name   string
+	Level  int32
+	Scale  [2]float32
+	Family uint8
+	Rank   int16
+}
+
+// Builds a WDB5 or WDB6 file of creatures, whose levels take 3 bytes. WDB6 files have a rank
+// for each creature in their common data.
+func wdb5_file(magic string, padded bool) []byte {
+	strings := []byte("\x00Wolf\x00Bear\x00")
+	records := le(
+		uint32(1), uint32(1), []byte{0xfe, 0xff, 0xff}, float32(1), float32(0.5), uint8(3),
+		uint32(2), uint32(6), []byte{70, 0, 0}, float32(2), float32(2), uint8(4),
+	)
+
+	header := le([]byte(magic), uint32(2), uint32(5), uint32(20), uint32(len(strings)),
+		uint32(0), uint32(0x1234), uint32(1), uint32(2), uint32(0), uint32(8), uint16(0), uint16(0))
+
+	// Each column of the common data has its values and their type
+	common := le(uint32(6))
+	for range 5 {
+		common = append(common, le(uint32(0), uint8(4))...)
+	}
+	common = append(common, le(uint32(1), uint8(1), uint32(2), int16(-3))...)
+	if padded {
+		common = append(common, 0, 0)
+	}
+	if magic == "WDB6" {
+		header = append(header, le(uint32(6), uint32(len(common)))...)
+	} else {
+		common = nil
+	}
+
+	// The size of each field, as 32 bits less a number of bits, and its offset
+	structures := le(int16(0), uint16(0), int16(0), uint16(4), int16(8), uint16(8), int16(0), uint16(11), int16(24), uint16(19))
+
+	// The third creature copies the first
+	copies := le(uint32(3), uint32(1))
+
+	return slices.Concat(header, structures, records, strings, copies, common)
+}
+
+func TestReadWDB5(t *testing.T) {
+	creatures, header, err := dbc.Read[creature](bytes.NewReader(wdb5_file("WDB5", false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []creature{
+		{ID: 1, Name: "Wolf", Level: -2, Scale: [2]float32{1, 0.5}, Family: 3},
+		{ID: 2, Name: "Bear", Level: 70, Scale: [2]float32{2, 2}, Family: 4},
+		{ID: 3, Name: "Wolf", Level: -2, Scale: [2]float32{1, 0.5}, Family: 3},
+	}
+	if !reflect.DeepEqual(creatures, expected) {
+		t.Fatal("got back incorrect records", creatures)
+	}
+
+	expected_header := dbc.Header{
+		Magic:           "WDB5",
+		RecordCount:     2,
+		FieldCount:      5,
+		RecordSize:      20,
+		StringBlockSize: 11,
+		LayoutHash:      0x1234,
+		MinID:           1,
+		MaxID:           2,
+		CopyTableSize:   8,
+		TotalFieldCount: 5,
+	}
+	if header != expected_header {
+		t.Fatalf("wrong header %+v", header)
+	}
+
+	for _, padded := range []bool{false, true} {
+		ranked, _, err := dbc.Read[ranked_creature](bytes.NewReader(wdb5_file("WDB6", padded)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, rank := range []int16{0, -3, 0} {
+			if ranked[i].Rank != rank || ranked[i].ID != expected[i].ID || ranked[i].Name != expected[i].Name {
+				t.Fatalf("got back incorrect record %+v", ranked[i])
+			}
+		}
+	}
+
+	if _, _, err := dbc.Read[ranked_creature](bytes.NewReader(wdb5_file("WDB5", false))); err == nil {
+		t.Fatal("expected an error for a record with the wrong number of fields")
+	}
+
+	// A malformed field structure, whose size works out to -8 bits
+	data := wdb5_file("WDB5", false)
+	binary.LittleEndian.PutUint16(data[48+8:], 40)
+	if _, _, err := dbc.Read[creature](bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "-8 bits") {
+		t.Fatal("expected an error for a field with a negative size, got", err)
+	}
+}
+
+type item struct {
+	ID      uint32 `dbc:"id"`
+	Name    string
+	Quality int8
+	Level   uint16
+	Flags   uint32
+	Display int32
+	Color   [3]uint8
+	Stats   []int16 `dbc:"len=2"`
+	Set     uint32  `dbc:"relation"`
+}
+
+// An item as it is stored, with the indices of its values in the pallet
+type item_row struct {
+	id      uint32
+	name    string
+	quality int8
+	level   uint16
+	display uint64
+	color   uint64
+	stats   [2]int16
+	set     uint32
+}
+
+var item_sections = [][]item_row{
+	{
+		{id: 10, name: "Sword", quality: -3, level: 60, display: 0, color: 1, stats: [2]int16{5, -7}, set: 1000},
+		{id: 11, name: "Shield", quality: 2, level: 1, display: 1, color: 0, stats: [2]int16{0, 300}},
+	},
+	{
+		{id: 20, name: "Axe", level: 127, display: 2, color: 1, stats: [2]int16{1, 1}, set: 2000},
+	},
+}
+
+var items = []item{
+	{ID: 10, Name: "Sword", Quality: -3, Level: 60, Flags: 0x10, Display: 100, Color: [3]uint8{4, 5, 6}, Stats: []int16{5, -7}, Set: 1000},
+	{ID: 11, Name: "Shield", Quality: 2, Level: 1, Flags: 0x20, Display: -5, Color: [3]uint8{1, 2, 3}, Stats: []int16{0, 300}},
+	{ID: 20, Name: "Axe", Level: 127, Flags: 0x10, Display: 7, Color: [3]uint8{4, 5, 6}, Stats: []int16{1, 1}, Set: 2000},
+	// A copy of the first item
+	{ID: 12, Name: "Sword", Quality: -3, Level: 60, Flags: 0x10, Display: 100, Color: [3]uint8{4, 5, 6}, Stats: []int16{5, -7}, Set: 1000},
+}
+
+// The data of a section of a WDC file
+type wdc_section struct {
+	tact_key_hash uint64
+	count         int
+	records       []byte
+	strings       []byte
+	ids           []byte
+	copies        []byte
+	relations     []byte
+}
+
+// Builds a WDC1, WDC2 or WDC3 file of items. WDC1 files have a single section, and the others
+// end with an encrypted section.
+func wdc_file(magic string) []byte {
+	const record_size = 10
+
+	sections := item_sections
+	if magic == "WDC1" {
+		sections = [][]item_row{slices.Concat(item_sections...)}
+	}
+	records_size := 0
+	for _, rows := range sections {
+		records_size += len(rows) * record_size
+	}
+
+	// The storage of each field: its offset and size in bits, the size of its pallet or common
+	// data, its type, and three values that depend on its type
+	info := le(
+		uint16(0), uint16(32), uint32(0), uint32(0), [3]uint32{},
+		uint16(32), uint16(5), uint32(0), uint32(5), [3]uint32{},
+		uint16(37), uint16(7), uint32(0), uint32(1), [3]uint32{},
+		uint16(44), uint16(0), uint32(8), uint32(2), [3]uint32{0x10, 0, 0},
+		uint16(44), uint16(2), uint32(12), uint32(3), [3]uint32{},
+		uint16(46), uint16(1), uint32(24), uint32(4), [3]uint32{0, 0, 3},
+		uint16(48), uint16(32), uint32(0), uint32(0), [3]uint32{},
+	)
+	pallet := le([]int32{100, -5, 7}, []uint32{1, 2, 3, 4, 5, 6})
+	common := le(uint32(11), uint32(0x20))
+	structures := make([]byte, 7*4)
+
+	var data []wdc_section
+	strings_size, position := 0, 0
+	for i, rows := range sections {
+		var (
+			s         = wdc_section{count: len(rows)}
+			relations []byte
+		)
+		for j, row := range rows {
+			// Strings are at offsets from the start of the string block in WDC1 files,
+			// and from the position of their field in later files
+			offset := strings_size + len(s.strings)
+			if magic != "WDC1" {
+				offset += records_size - position
+			}
+
+			record := make([]byte, record_size)
+			put_bits(record, 0, 32, uint64(offset))
+			put_bits(record, 32, 5, uint64(row.quality))
+			put_bits(record, 37, 7, uint64(row.level))
+			put_bits(record, 44, 2, row.display)
+			put_bits(record, 46, 1, row.color)
+			put_bits(record, 48, 16, uint64(uint16(row.stats[0])))
+			put_bits(record, 64, 16, uint64(uint16(row.stats[1])))
+
+			s.records = append(s.records, record...)
+			s.strings = append(append(s.strings, row.name...), 0)
+			s.ids = append(s.ids, le(row.id)...)
+			if row.set != 0 {
+				relations = append(relations, le(row.set, uint32(j))...)
+			}
+			position += record_size
+		}
+		strings_size += len(s.strings)
+
+		s.relations = append(le(uint32(len(relations)/8), uint32(1000), uint32(2000)), relations...)
+		if i == 0 {
+			s.copies = le(uint32(12), uint32(10))
+		}
+		data = append(data, s)
+	}
+
+	if magic == "WDC1" {
+		s := data[0]
+		header := le([]byte(magic), uint32(s.count), uint32(7), uint32(record_size), uint32(len(s.strings)),
+			uint32(0), uint32(0xabcd), uint32(10), uint32(20), uint32(0), uint32(len(s.copies)), uint16(0), uint16(0),
+			uint32(7), uint32(4), uint32(0), uint32(0), uint32(len(s.ids)),
+			uint32(len(info)), uint32(len(common)), uint32(len(pallet)), uint32(len(s.relations)))
+		return slices.Concat(header, structures, s.records, s.strings, s.ids, s.copies, info, pallet, common, s.relations)
+	}
+
+	// A section that is still encrypted is zeroed out
+	data = append(data, wdc_section{tact_key_hash: 0x1234, count: 1, records: make([]byte, record_size)})
+
+	header := le([]byte(magic), uint32(position/record_size), uint32(7), uint32(record_size), uint32(strings_size),
+		uint32(0), uint32(0xabcd), uint32(10), uint32(20), uint32(0), uint16(0), uint16(0),
+		uint32(7), uint32(4), uint32(0), uint32(len(info)), uint32(len(common)), uint32(len(pallet)), uint32(len(data)))
+
+	section_size := 36
+	if magic == "WDC3" {
+		section_size = 40
+	}
+	offset := len(header) + len(data)*section_size + len(structures) + len(info) + len(pallet) + len(common)
+
+	var sections_data []byte
+	for _, s := range data {
+		if magic == "WDC2" {
+			header = append(header, le(s.tact_key_hash, uint32(offset), uint32(s.count), uint32(len(s.strings)),
+				uint32(len(s.copies)), uint32(0), uint32(len(s.ids)), uint32(len(s.relations)))...)
+		} else {
+			header = append(header, le(s.tact_key_hash, uint32(offset), uint32(s.count), uint32(len(s.strings)),
+				uint32(0), uint32(len(s.ids)), uint32(len(s.relations)), uint32(0), uint32(len(s.copies)/8))...)
+		}
+		section := slices.Concat(s.records, s.strings, s.ids, s.copies, s.relations)
+		sections_data = append(sections_data, section...)
+		offset += len(section)
+	}
+
+	return slices.Concat(header, structures, info, pallet, common, sections_data)
+}
+
+func TestReadWDC(t *testing.T) {
+	for _, magic := range []string{"WDC1", "WDC2", "WDC3"} {
+		records, header, err := dbc.Read[item](bytes.NewReader(wdc_file(magic)))
+		if err != nil {
+			t.Fatal(magic, err)
+		}
+		if !reflect.DeepEqual(records, items) {
+			t.Fatalf("got back incorrect %s records %+v", magic, records)
+		}
+		if header.LayoutHash != 0xabcd || header.TotalFieldCount != 7 || header.MinID != 10 || header.MaxID != 20 {
+			t.Fatalf("wrong %s header %+v", magic, header)
+		}
+
+		// A truncated file
+		data := wdc_file(magic)
+		if _, _, err = dbc.Read[item](bytes.NewReader(data[:len(data)-30])); err == nil {
+			t.Fatal("expected an error for a truncated", magic, "file")
+		}
+	}
+}
+
+type note struct {
+	ID    uint32 `dbc:"id"`
+	Text  string `dbc:"inline"`
+	Level uint16
+}
+
+type plain_note struct {
+	ID    uint32 `dbc:"id"`
+	Text  string
+	Level uint16
+}
+
+func TestReadSparse(t *testing.T) {
+	info := le(
+		uint16(0), uint16(0), uint32(0), uint32(0), [3]uint32{},
+		uint16(0), uint16(16), uint32(0), uint32(0), [3]uint32{},
+	)
+	records_offset := 72 + 40 + 2*4 + len(info)
+
+	// Records are listed by an offset map, and their IDs follow it
+	var records, offset_map []byte
+	for _, record := range [][]byte{le([]byte("Hello\x00"), uint16(5)), le([]byte("World!\x00"), uint16(9))} {
+		offset_map = append(offset_map, le(uint32(records_offset+len(records)), uint16(len(record)))...)
+		records = append(records, record...)
+	}
+
+	data := slices.Concat(
+		le([]byte("WDC3"), uint32(2), uint32(2), uint32(0), uint32(0), uint32(0), uint32(0), uint32(7), uint32(9), uint32(0),
+			uint16(1), uint16(0), uint32(2), uint32(0), uint32(0), uint32(len(info)), uint32(0), uint32(0), uint32(1)),
+		le(uint64(0), uint32(records_offset), uint32(2), uint32(0), uint32(records_offset+len(records)),
+			uint32(0), uint32(0), uint32(2), uint32(0)),
+		make([]byte, 2*4), info, records, offset_map, le(uint32(7), uint32(9)),
+	)
+
+	notes, _, err := dbc.Read[note](bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []note{{ID: 7, Text: "Hello", Level: 5}, {ID: 9, Text: "World!", Level: 9}}
+	if !reflect.DeepEqual(notes, expected) {
+		t.Fatal("got back incorrect records", notes)
+	}
+
+	if _, _, err = dbc.Read[plain_note](bytes.NewReader(data)); err == nil {
+		t.Fatal("expected an error for a string that is not tagged inline")
+	}
+}
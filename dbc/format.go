@@ -0,0 +1,481 @@
+package dbc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The flags of DB2 files
+const (
+	// Records have variable sizes, and are listed by an offset map
+	flag_offset_map = 0x01
+	// The IDs of records are stored in an ID list
+	flag_id_list = 0x04
+)
+
+// The sizes of the section headers of WDC2 and WDC3 files
+const (
+	wdc2_section_size = 36
+	wdc3_section_size = 40
+)
+
+// Parses a client database file whose records are described by a layout
+func parse(data []byte, l *layout) (t *table, err error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("dbc: file is too short")
+	}
+
+	r := &reader{data: data}
+	t = new(table)
+	t.header.Magic = string(r.bytes(4))
+
+	switch t.header.Magic {
+	case "WDBC", "WDB2", "WDB3", "WDB4":
+		t.parse_wdb(r)
+	case "WDB5", "WDB6":
+		err = t.parse_wdb5(r, l)
+	case "WDC1":
+		err = t.parse_wdc1(r, l)
+	case "WDC2", "WDC3":
+		err = t.parse_wdc(r, l)
+	case "WDC4", "WDC5", "1SLC":
+		err = fmt.Errorf("%w %q", ErrUnsupportedFormat, t.header.Magic)
+	default:
+		err = fmt.Errorf("dbc: not a client database file")
+	}
+
+	if err == nil && r.short {
+		err = fmt.Errorf("dbc: %s file is too short", t.header.Magic)
+	}
+	return
+}
+
+// Returns the number of IDs from MinID to MaxID
+func (t *table) id_range() int {
+	return int(t.header.MaxID) - int(t.header.MinID) + 1
+}
+
+// Parses WDBC files, and the WDB2 to WDB4 files whose records are laid out in the same way
+func (t *table) parse_wdb(r *reader) {
+	h := &t.header
+	h.RecordCount, h.FieldCount, h.RecordSize, h.StringBlockSize = r.u32(), r.u32(), r.u32(), r.u32()
+	if h.Magic != "WDBC" {
+		h.TableHash, h.Build, h.Timestamp, h.MinID, h.MaxID, h.Locale, h.CopyTableSize = r.u32(), r.u32(), r.u32(), r.u32(), r.u32(), r.u32(), r.u32()
+	}
+	if h.Magic == "WDB4" {
+		h.Flags = r.u32()
+	}
+	t.legacy = true
+
+	// Newer WDB2 files list the record for each ID, and the length of its strings
+	if h.Magic == "WDB2" && h.MaxID != 0 && h.Build > 12880 {
+		r.bytes(t.id_range() * 6)
+	}
+
+	t.read_wdb_body(r)
+}
+
+// Parses WDB5 and WDB6 files, which store the size and position of each field
+func (t *table) parse_wdb5(r *reader, l *layout) (err error) {
+	h := &t.header
+	h.RecordCount, h.FieldCount, h.RecordSize, h.StringBlockSize = r.u32(), r.u32(), r.u32(), r.u32()
+	h.TableHash, h.LayoutHash, h.MinID, h.MaxID, h.Locale, h.CopyTableSize = r.u32(), r.u32(), r.u32(), r.u32(), r.u32(), r.u32()
+	h.Flags, h.IDIndex = uint32(r.u16()), r.u16()
+	h.TotalFieldCount = h.FieldCount
+
+	var common_size uint32
+	if h.Magic == "WDB6" {
+		h.TotalFieldCount, common_size = r.u32(), r.u32()
+	}
+	if h.TotalFieldCount < h.FieldCount {
+		return fmt.Errorf("dbc: file has %d fields in its records, but %d in total", h.FieldCount, h.TotalFieldCount)
+	}
+	if err = t.make_storage(l, h.TotalFieldCount); err != nil {
+		return
+	}
+	t.id_index = int(h.IDIndex)
+
+	// Each field has a size of 32 bits less a number of bits, and a byte offset
+	structures := r.bytes(int(h.FieldCount) * 4)
+	for i := range len(structures) / 4 {
+		s := &t.storage[i]
+		s.size = 32 - int(int16(binary.LittleEndian.Uint16(structures[4*i:])))
+		s.offset = int(binary.LittleEndian.Uint16(structures[4*i+2:])) * 8
+
+		// The length of an array is the space before the next field
+		if i > 0 && t.storage[i-1].size > 0 {
+			previous := &t.storage[i-1]
+			previous.count = (s.offset - previous.offset) / previous.size
+		}
+	}
+
+	// Fields after those in the records are in the common data
+	for i := int(h.FieldCount); i < len(t.storage); i++ {
+		t.storage[i] = storage{kind: storage_common, size: 32}
+	}
+
+	t.read_wdb_body(r)
+	if h.Magic == "WDB6" {
+		return t.read_wdb6_common(r.bytes(int(common_size)))
+	}
+	return
+}
+
+// Reads the records, string block, ID list and copy table of files up to WDB6
+func (t *table) read_wdb_body(r *reader) {
+	h := &t.header
+	if h.Flags&flag_offset_map != 0 {
+		// The size of the string block is replaced by the position of the offset map
+		r.seek(int(h.StringBlockSize))
+		t.add_sparse_records(r, r.bytes(t.id_range()*6), nil)
+	} else {
+		t.read_records(r, int(h.RecordCount), int(h.RecordSize), int(h.StringBlockSize))
+	}
+
+	if h.Flags&flag_id_list != 0 {
+		t.set_ids(0, r.bytes(int(h.RecordCount)*4))
+	}
+	t.add_copies(r.bytes(int(h.CopyTableSize)))
+}
+
+// Reads the common data of WDB6 files, which lists the values of fields by ID with a type for each field
+func (t *table) read_wdb6_common(data []byte) (err error) {
+	// Each value takes the size of its type, or 4 bytes in files from later builds
+	for _, padded := range []bool{false, true} {
+		r := &reader{data: data}
+		fields := make([]storage, len(t.storage))
+		copy(fields, t.storage)
+
+		count := int(r.u32())
+		for i := 0; i < count && !r.short; i++ {
+			values, kind := int(r.u32()), r.u8()
+
+			size := 4
+			switch kind {
+			case 1:
+				size = 2
+			case 2:
+				size = 1
+			}
+
+			common := make(map[uint32]uint32)
+			for range values {
+				id := r.u32()
+				value := r.bytes(size)
+				if padded {
+					r.bytes(4 - size)
+				}
+				if r.short {
+					break
+				}
+				common[id] = uint32(read_uint(value, size))
+			}
+
+			if i < len(fields) && fields[i].kind == storage_common {
+				fields[i].size = size * 8
+				fields[i].common = common
+			}
+		}
+
+		if !r.short && r.offset == len(data) {
+			t.storage = fields
+			return
+		}
+	}
+	return fmt.Errorf("dbc: the common data of the file is malformed")
+}
+
+// Parses WDC1 files, whose fields may be bit-packed or compressed
+func (t *table) parse_wdc1(r *reader, l *layout) (err error) {
+	h := &t.header
+	h.RecordCount, h.FieldCount, h.RecordSize, h.StringBlockSize = r.u32(), r.u32(), r.u32(), r.u32()
+	h.TableHash, h.LayoutHash, h.MinID, h.MaxID, h.Locale, h.CopyTableSize = r.u32(), r.u32(), r.u32(), r.u32(), r.u32(), r.u32()
+	h.Flags, h.IDIndex = uint32(r.u16()), r.u16()
+	h.TotalFieldCount = r.u32()
+
+	r.bytes(8) // The position of the bit-packed data, and the number of lookup columns
+	offset_map_offset, id_list_size := r.u32(), r.u32()
+	storage_size, common_size, pallet_size, relationship_size := r.u32(), r.u32(), r.u32(), r.u32()
+
+	if err = t.make_storage(l, h.TotalFieldCount); err != nil {
+		return
+	}
+	t.id_index = int(h.IDIndex)
+
+	// The field structures are described again by the field storage info
+	r.bytes(int(h.TotalFieldCount) * 4)
+
+	if h.Flags&flag_offset_map != 0 {
+		r.seek(int(offset_map_offset))
+		t.add_sparse_records(r, r.bytes(t.id_range()*6), nil)
+	} else {
+		t.read_records(r, int(h.RecordCount), int(h.RecordSize), int(h.StringBlockSize))
+	}
+	t.set_ids(0, r.bytes(int(id_list_size)))
+	t.add_copies(r.bytes(int(h.CopyTableSize)))
+
+	info, pallet, common := r.bytes(int(storage_size)), r.bytes(int(pallet_size)), r.bytes(int(common_size))
+	if r.short {
+		return
+	}
+	if err = t.read_storage(info, pallet, common); err != nil {
+		return
+	}
+	t.add_relations(0, r.bytes(int(relationship_size)))
+	return
+}
+
+// A section of a WDC2 or WDC3 file
+type section struct {
+	tact_key_hash     uint64
+	file_offset       int
+	record_count      int
+	string_table_size int
+	// The size of the copy table in bytes
+	copy_table_size int
+	// The position of the offset map in WDC2 files, or of the end of the records in WDC3 files
+	offset_map_offset  int
+	id_list_size       int
+	relationship_size  int
+	offset_map_entries int
+}
+
+// Parses WDC2 and WDC3 files, whose records are divided into sections
+func (t *table) parse_wdc(r *reader, l *layout) (err error) {
+	h := &t.header
+	h.RecordCount, h.FieldCount, h.RecordSize, h.StringBlockSize = r.u32(), r.u32(), r.u32(), r.u32()
+	h.TableHash, h.LayoutHash, h.MinID, h.MaxID, h.Locale = r.u32(), r.u32(), r.u32(), r.u32(), r.u32()
+	h.Flags, h.IDIndex = uint32(r.u16()), r.u16()
+	h.TotalFieldCount = r.u32()
+
+	r.bytes(8) // The position of the bit-packed data, and the number of lookup columns
+	storage_size, common_size, pallet_size, section_count := r.u32(), r.u32(), r.u32(), r.u32()
+
+	if err = t.make_storage(l, h.TotalFieldCount); err != nil {
+		return
+	}
+	t.id_index = int(h.IDIndex)
+	t.relative = true
+
+	section_size := wdc2_section_size
+	if h.Magic == "WDC3" {
+		section_size = wdc3_section_size
+	}
+	headers := r.bytes(int(section_count) * section_size)
+
+	// The field structures are described again by the field storage info
+	r.bytes(int(h.TotalFieldCount) * 4)
+
+	info, pallet, common := r.bytes(int(storage_size)), r.bytes(int(pallet_size)), r.bytes(int(common_size))
+	if r.short {
+		return
+	}
+	if err = t.read_storage(info, pallet, common); err != nil {
+		return
+	}
+
+	for i := range len(headers) / section_size {
+		s := &reader{data: headers[i*section_size:]}
+		var sec section
+		sec.tact_key_hash = s.u64()
+		sec.file_offset, sec.record_count, sec.string_table_size = int(s.u32()), int(s.u32()), int(s.u32())
+		if h.Magic == "WDC2" {
+			sec.copy_table_size, sec.offset_map_offset = int(s.u32()), int(s.u32())
+			sec.id_list_size, sec.relationship_size = int(s.u32()), int(s.u32())
+		} else {
+			sec.offset_map_offset, sec.id_list_size, sec.relationship_size = int(s.u32()), int(s.u32()), int(s.u32())
+			sec.offset_map_entries, sec.copy_table_size = int(s.u32()), int(s.u32())*8
+		}
+
+		t.read_section(r, &sec)
+		if r.short {
+			return
+		}
+	}
+	return
+}
+
+// Reads the records of a section of a WDC2 or WDC3 file
+func (t *table) read_section(r *reader, sec *section) {
+	h := &t.header
+	sparse := h.Flags&flag_offset_map != 0
+
+	// Sections that are still encrypted have been zeroed out
+	end := sec.file_offset + sec.record_count*int(h.RecordSize) + sec.string_table_size
+	if sparse {
+		end = sec.offset_map_offset
+	}
+	if sec.tact_key_hash != 0 && sec.file_offset <= end && end <= len(r.data) && is_zero(r.data[sec.file_offset:end]) {
+		return
+	}
+
+	r.seek(sec.file_offset)
+	first := len(t.records)
+
+	if !sparse {
+		t.read_records(r, sec.record_count, int(h.RecordSize), sec.string_table_size)
+		t.set_ids(first, r.bytes(sec.id_list_size))
+		t.add_copies(r.bytes(sec.copy_table_size))
+		t.add_relations(first, r.bytes(sec.relationship_size))
+		return
+	}
+
+	if h.Magic == "WDC2" {
+		r.seek(sec.offset_map_offset)
+		t.add_sparse_records(r, r.bytes(t.id_range()*6), nil)
+		t.set_ids(first, r.bytes(sec.id_list_size))
+		t.add_copies(r.bytes(sec.copy_table_size))
+		t.add_relations(first, r.bytes(sec.relationship_size))
+		return
+	}
+
+	// The IDs of the records in the offset map are listed at the end of the section
+	r.seek(sec.offset_map_offset)
+	ids := r.bytes(sec.id_list_size)
+	copies := r.bytes(sec.copy_table_size)
+	offset_map := r.bytes(sec.offset_map_entries * 6)
+	relations := r.bytes(sec.relationship_size)
+	offset_map_ids := r.bytes(sec.offset_map_entries * 4)
+
+	t.add_sparse_records(r, offset_map, offset_map_ids)
+	t.set_ids(first, ids)
+	t.add_copies(copies)
+	t.add_relations(first, relations)
+}
+
+// Makes the storage of each field, once the number of fields is checked against the layout
+func (t *table) make_storage(l *layout, count uint32) (err error) {
+	if count != uint32(len(l.fields)) {
+		return fmt.Errorf("dbc: records of %s have %d fields, but the file has %d", l.record, len(l.fields), count)
+	}
+	t.storage = make([]storage, count)
+	return
+}
+
+// Reads the field storage info of WDC files, with the pallet and common data that it refers to
+func (t *table) read_storage(info, pallet, common []byte) (err error) {
+	if len(info) < len(t.storage)*24 {
+		return fmt.Errorf("dbc: file has storage info for %d fields, but %d fields", len(info)/24, len(t.storage))
+	}
+
+	for i := range t.storage {
+		r := &reader{data: info[i*24 : (i+1)*24]}
+		s := storage{offset: int(r.u16()), size: int(r.u16())}
+		additional_size := int(r.u32())
+		s.kind = int(r.u32())
+		values := [3]uint32{r.u32(), r.u32(), r.u32()}
+
+		switch s.kind {
+		case storage_none:
+			s.total = true
+		case storage_common:
+			s.size = 32
+			s.default_value = values[0]
+			if additional_size > len(common) {
+				return fmt.Errorf("dbc: the common data of field %d is outside of the file", i)
+			}
+			s.common = make(map[uint32]uint32, additional_size/8)
+			for j := 0; j+8 <= additional_size; j += 8 {
+				s.common[binary.LittleEndian.Uint32(common[j:])] = binary.LittleEndian.Uint32(common[j+4:])
+			}
+			common = common[additional_size:]
+		case storage_pallet, storage_pallet_array:
+			if additional_size > len(pallet) {
+				return fmt.Errorf("dbc: the pallet of field %d is outside of the file", i)
+			}
+			s.pallet = make([]uint32, additional_size/4)
+			for j := range s.pallet {
+				s.pallet[j] = binary.LittleEndian.Uint32(pallet[4*j:])
+			}
+			pallet = pallet[additional_size:]
+			if s.kind == storage_pallet_array {
+				s.count = int(values[2])
+			}
+		}
+		t.storage[i] = s
+	}
+	return
+}
+
+// Reads count fixed-size records, followed by a string block that is added to the strings of the file
+func (t *table) read_records(r *reader, count, size, strings_size int) {
+	data := r.bytes(count * size)
+	strings := r.bytes(strings_size)
+	if r.short {
+		return
+	}
+
+	for i := range count {
+		t.records = append(t.records, record{data: data[i*size : (i+1)*size], position: t.records_size + i*size})
+	}
+	t.records_size += count * size
+	t.strings.data = append(t.strings.data, strings...)
+}
+
+// Adds the records listed by an offset map, each at a position in the file with a size.
+// Their IDs are listed in ids, or count up from MinID if it is nil.
+func (t *table) add_sparse_records(r *reader, offset_map []byte, ids []byte) {
+	t.sparse = true
+	for i := range len(offset_map) / 6 {
+		offset := int(binary.LittleEndian.Uint32(offset_map[6*i:]))
+		size := int(binary.LittleEndian.Uint16(offset_map[6*i+4:]))
+		if offset == 0 || size == 0 {
+			continue
+		}
+		if offset+size > len(r.data) {
+			r.short = true
+			return
+		}
+
+		id := t.header.MinID + uint32(i)
+		if ids != nil {
+			if 4*i+4 > len(ids) {
+				r.short = true
+				return
+			}
+			id = binary.LittleEndian.Uint32(ids[4*i:])
+		}
+		t.records = append(t.records, record{data: r.data[offset : offset+size], id: id, has_id: true})
+	}
+}
+
+// Sets the IDs of records from an ID list, beginning with the record at first
+func (t *table) set_ids(first int, ids []byte) {
+	for i := 0; first+i < len(t.records) && 4*i+4 <= len(ids); i++ {
+		t.records[first+i].id = binary.LittleEndian.Uint32(ids[4*i:])
+		t.records[first+i].has_id = true
+	}
+}
+
+// Adds the entries of a copy table, each the ID of a new record and the ID of the record it copies
+func (t *table) add_copies(copies []byte) {
+	for i := 0; 8*i+8 <= len(copies); i++ {
+		t.copies = append(t.copies, [2]uint32{binary.LittleEndian.Uint32(copies[8*i:]), binary.LittleEndian.Uint32(copies[8*i+4:])})
+	}
+}
+
+// Sets the foreign keys of records from relationship data, whose record indices begin with the record at first
+func (t *table) add_relations(first int, data []byte) {
+	if len(data) < 12 {
+		return
+	}
+
+	// The number of entries is followed by the smallest and largest foreign key
+	count := int(binary.LittleEndian.Uint32(data))
+	entries := data[12:]
+	for i := 0; i < count && 8*i+8 <= len(entries); i++ {
+		index := first + int(binary.LittleEndian.Uint32(entries[8*i+4:]))
+		if index < len(t.records) {
+			t.records[index].relation = binary.LittleEndian.Uint32(entries[8*i:])
+		}
+	}
+}
+
+func is_zero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
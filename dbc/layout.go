@@ -0,0 +1,375 @@
+package dbc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A step from a value to one of its struct fields or array elements
+type step struct {
+	element bool
+	index   int
+	// For the elements of a slice, the length it is given
+	length int
+}
+
+// A value stored in a record: a number, a bool, a string or padding
+type slot struct {
+	// The steps from the record to the value, or nil for padding
+	path []step
+	kind reflect.Kind
+	// The number of bytes the value takes in WDBC and WDB2 records
+	size int
+	// Set for strings stored in the record rather than in the string block
+	inline bool
+	// The field that the value belongs to, and its element within the field, or -1 for padding
+	field   int
+	element int
+	// The offset of the value in WDBC and WDB2 records
+	offset int
+}
+
+// A field as the DB2 formats count them: a single value, or an array of values
+type layout_field struct {
+	name string
+	// The index of the slot of each element
+	slots []int
+}
+
+// The slots of a record type, in the order they are stored
+type layout struct {
+	record reflect.Type
+	slots  []slot
+	fields []layout_field
+	size   int
+	// The number of values, counting each array element
+	values int
+	// Fields that are not stored in the record itself, which may be nil
+	id       *slot
+	relation *slot
+	// Set if some strings are stored in the record
+	inline bool
+}
+
+// The options of a `dbc` struct tag
+type tag_options struct {
+	skip     bool
+	size     int
+	length   int
+	inline   bool
+	id       bool
+	relation bool
+}
+
+// Parses a `dbc` struct tag
+func parse_tag(field reflect.StructField) (options tag_options, err error) {
+	tag := field.Tag.Get("dbc")
+	if tag == "-" {
+		options.skip = true
+		return
+	}
+
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "":
+		case "size":
+			if options.size, err = strconv.Atoi(value); err != nil || options.size < 1 || options.size > 8 {
+				return options, fmt.Errorf("dbc: field %s has an invalid size %q", field.Name, value)
+			}
+		case "len":
+			if options.length, err = strconv.Atoi(value); err != nil || options.length < 0 {
+				return options, fmt.Errorf("dbc: field %s has an invalid length %q", field.Name, value)
+			}
+		case "inline":
+			options.inline = true
+		case "id":
+			options.id = true
+		case "relation":
+			options.relation = true
+		default:
+			return options, fmt.Errorf("dbc: field %s has an unknown tag option %q", field.Name, option)
+		}
+	}
+	return
+}
+
+var layouts sync.Map
+
+// Returns the layout of records of type t
+func get_layout(t reflect.Type) (l *layout, err error) {
+	if cached, ok := layouts.Load(t); ok {
+		return cached.(*layout), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("dbc: a record must be a struct, not %s", t)
+	}
+
+	l = &layout{record: t}
+	if err = l.add_struct(t, nil); err != nil {
+		return nil, err
+	}
+
+	layouts.Store(t, l)
+	return
+}
+
+func (l *layout) add_struct(t reflect.Type, path []step) (err error) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		var options tag_options
+		if options, err = parse_tag(field); err != nil {
+			return
+		}
+		if options.skip {
+			continue
+		}
+
+		// Blank fields are padding
+		if field.Name == "_" {
+			l.slots = append(l.slots, slot{size: int(field.Type.Size()), field: -1, offset: l.size})
+			l.size += int(field.Type.Size())
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		field_path := append(path[:len(path):len(path)], step{index: i})
+
+		// IDs and relations are kept apart from the record in DB2 files
+		if options.id || options.relation {
+			if !is_integer(field.Type.Kind()) {
+				return fmt.Errorf("dbc: field %s must be an integer to hold an ID", field.Name)
+			}
+			s := &slot{path: field_path, kind: field.Type.Kind(), field: -1}
+			if options.id {
+				l.id = s
+			} else {
+				l.relation = s
+			}
+			continue
+		}
+
+		if err = l.add(field.Type, field_path, field.Name, options, false); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Adds the slots of a value. Each value that is not a struct or an array element begins a new field.
+func (l *layout) add(t reflect.Type, path []step, name string, options tag_options, element bool) (err error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		return l.add_struct(t, path)
+	case reflect.Array, reflect.Slice:
+		length := options.length
+		if t.Kind() == reflect.Array {
+			length = t.Len()
+		} else if length == 0 {
+			return fmt.Errorf("dbc: slice field %s needs a length, as in `dbc:\"len=4\"`", name)
+		}
+
+		// An array of values is one field, while an array of structs holds the fields of each struct
+		if t.Elem().Kind() != reflect.Struct {
+			l.fields = append(l.fields, layout_field{name: name})
+		}
+		for i := range length {
+			if err = l.add(t.Elem(), append(path[:len(path):len(path)], step{element: true, index: i, length: length}), name, options, true); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	size := 0
+	switch t.Kind() {
+	case reflect.Int8, reflect.Uint8:
+		size = 1
+	case reflect.Int16, reflect.Uint16:
+		size = 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32, reflect.String, reflect.Bool:
+		// Strings are stored as offsets into the string block, and bools as 32-bit integers
+		size = 4
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		size = 8
+	default:
+		return fmt.Errorf("dbc: field %s has type %s, which cannot be stored in a record", name, t)
+	}
+
+	if options.size != 0 {
+		switch {
+		case t.Kind() == reflect.String:
+			return fmt.Errorf("dbc: string field %s cannot be given a size", name)
+		case t.Kind() == reflect.Float32 && options.size != 4, t.Kind() == reflect.Float64 && options.size != 8:
+			return fmt.Errorf("dbc: float field %s cannot have a size of %d bytes", name, options.size)
+		}
+		size = options.size
+	}
+	if options.inline {
+		if t.Kind() != reflect.String {
+			return fmt.Errorf("dbc: field %s is not a string, so it cannot be inline", name)
+		}
+		l.inline = true
+	}
+
+	if !element {
+		l.fields = append(l.fields, layout_field{name: name})
+	}
+	f := &l.fields[len(l.fields)-1]
+
+	l.slots = append(l.slots, slot{
+		path:    path,
+		kind:    t.Kind(),
+		size:    size,
+		inline:  options.inline,
+		field:   len(l.fields) - 1,
+		element: len(f.slots),
+		offset:  l.size,
+	})
+	f.slots = append(f.slots, len(l.slots)-1)
+	l.size += size
+	l.values++
+	return
+}
+
+func is_integer(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return true
+	}
+	return false
+}
+
+func is_signed(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return true
+	}
+	return false
+}
+
+// Returns the value of a slot within a record. Slices are allocated when reading, and
+// elements missing from a slice are invalid values when writing.
+func (s *slot) value(record reflect.Value, allocate bool) reflect.Value {
+	value := record
+	for _, step := range s.path {
+		switch {
+		case !step.element:
+			value = value.Field(step.index)
+		case value.Kind() == reflect.Slice && value.Len() != step.length:
+			if !allocate {
+				if step.index >= value.Len() {
+					return reflect.Value{}
+				}
+				value = value.Index(step.index)
+				continue
+			}
+			value.Set(reflect.MakeSlice(value.Type(), step.length, step.length))
+			fallthrough
+		default:
+			value = value.Index(step.index)
+		}
+	}
+	return value
+}
+
+// Sets a slot from the bits of a stored value, which are sign-extended from the given width if extend is set
+func (s *slot) set(record reflect.Value, bits uint64, width int, extend bool) {
+	value := s.value(record, true)
+	switch s.kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if extend && width > 0 && width < 64 {
+			bits = uint64(int64(bits<<(64-width)) >> (64 - width))
+		}
+		value.SetInt(int64(bits))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		value.SetUint(bits)
+	case reflect.Float32:
+		value.SetFloat(float64(math.Float32frombits(uint32(bits))))
+	case reflect.Float64:
+		value.SetFloat(math.Float64frombits(bits))
+	case reflect.Bool:
+		value.SetBool(bits != 0)
+	}
+}
+
+// Returns the bits of a slot's value as it is stored
+func (s *slot) get(record reflect.Value) uint64 {
+	value := s.value(record, false)
+	if !value.IsValid() {
+		return 0
+	}
+
+	switch s.kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return uint64(value.Int())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return value.Uint()
+	case reflect.Float32:
+		return uint64(math.Float32bits(float32(value.Float())))
+	case reflect.Float64:
+		return math.Float64bits(value.Float())
+	case reflect.Bool:
+		if value.Bool() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Appends a record to data in the WDBC and WDB2 layout, adding its strings to the string block
+func (l *layout) append(data []byte, block *string_block, record reflect.Value) []byte {
+	for i := range l.slots {
+		s := &l.slots[i]
+		switch {
+		case s.path == nil:
+			data = append(data, make([]byte, s.size)...)
+		case s.kind == reflect.String:
+			var str string
+			if value := s.value(record, false); value.IsValid() {
+				str = value.String()
+			}
+			data = append_uint(data, uint64(block.add(str)), 4)
+		default:
+			data = append_uint(data, s.get(record), s.size)
+		}
+	}
+	return data
+}
+
+// Appends the low size bytes of an integer
+func append_uint(data []byte, u uint64, size int) []byte {
+	for i := range size {
+		data = append(data, byte(u>>(8*i)))
+	}
+	return data
+}
+
+// Reads a little-endian integer of size bytes
+func read_uint(data []byte, size int) (u uint64) {
+	for i := range size {
+		u |= uint64(data[i]) << (8 * i)
+	}
+	return
+}
+
+// Reads size bits beginning at a bit offset, with the least significant bits first
+func read_bits(data []byte, offset, size int) (u uint64) {
+	for i, bit := 0, offset; i < size; {
+		n := min(8-bit%8, size-i)
+		u |= (uint64(data[bit/8]>>(bit%8)) & (1<<n - 1)) << i
+		i += n
+		bit += n
+	}
+	return
+}
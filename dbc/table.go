@@ -0,0 +1,367 @@
+package dbc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// The ways that the values of a field are stored, numbered as in the field storage info of WDC files
+const (
+	// Stored in the record, taking a whole number of bytes
+	storage_none = iota
+	// Stored in the record, in some number of bits
+	storage_bitpacked
+	// Not stored in the record, but looked up by its ID, with a default for other IDs
+	storage_common
+	// Stored in the record as an index into a list of values
+	storage_pallet
+	// Stored in the record as an index into a list of arrays
+	storage_pallet_array
+	// Stored in the record in some number of bits, with a sign
+	storage_bitpacked_signed
+)
+
+// How the values of a field are stored in the records of a file
+type storage struct {
+	kind int
+	// The position of the first value in a record, and the size of each value, in bits.
+	// For pallets, this is the position and size of the index.
+	offset int
+	size   int
+	// Set if size is the size of the whole array rather than each value
+	total bool
+	// The number of values in the field, or 0 if it is not known
+	count int
+	// The values of common fields by ID, and the value of other IDs
+	common        map[uint32]uint32
+	default_value uint32
+	// The values of pallet fields
+	pallet []uint32
+}
+
+// Returns the bits of a value of the field in a record, the number of bits that they have, and
+// whether they are signed
+func (s *storage) read(r *record, element int) (bits uint64, width int, signed bool, err error) {
+	switch s.kind {
+	case storage_none, storage_bitpacked, storage_bitpacked_signed:
+		offset := s.offset + element*s.size
+		if offset+s.size > len(r.data)*8 {
+			err = errors.New("dbc: value is outside of its record")
+			return
+		}
+		return read_bits(r.data, offset, s.size), s.size, s.kind != storage_bitpacked, nil
+	case storage_common:
+		value, ok := s.common[r.id]
+		if !ok {
+			value = s.default_value
+		}
+		return uint64(value), s.size, true, nil
+	case storage_pallet, storage_pallet_array:
+		if s.offset+s.size > len(r.data)*8 {
+			err = errors.New("dbc: value is outside of its record")
+			return
+		}
+		index := read_bits(r.data, s.offset, s.size)
+		if s.kind == storage_pallet_array {
+			index = index*uint64(s.count) + uint64(element)
+		}
+		if index >= uint64(len(s.pallet)) {
+			err = fmt.Errorf("dbc: pallet index %d is out of range", index)
+			return
+		}
+		return uint64(s.pallet[index]), 32, true, nil
+	}
+	return 0, 0, false, fmt.Errorf("dbc: unknown field storage %d", s.kind)
+}
+
+// A record in a file
+type record struct {
+	data []byte
+	// The position of the record among the records of the file, for strings stored at offsets relative to their field
+	position int
+	id       uint32
+	// Set once the ID is known, which it is unless it is stored in the record
+	has_id bool
+	// Set if the ID was read from a field of the record
+	inline_id bool
+	relation  uint32
+	// Set for records from the copy table, whose ID differs from the record they were copied from
+	copied bool
+}
+
+// The records of a client database file, and how to read their fields
+type table struct {
+	header  Header
+	records []record
+	storage []storage
+	strings string_block
+	// Set if strings are stored at offsets from the position of their field, rather than from the start of the string block
+	relative bool
+	// The size of the records that strings are stored relative to
+	records_size int
+	// Set if records have variable sizes and store their strings inline, as listed by an offset map
+	sparse bool
+	// Set for formats whose records are laid out as in WDBC files
+	legacy bool
+	// The field that holds the ID of records without an ID list
+	id_index int
+	// Pairs of IDs of new records and the records they copy
+	copies [][2]uint32
+}
+
+// Checks that the records of a file match a layout
+func (t *table) check(l *layout) (err error) {
+	if t.legacy {
+		if t.header.FieldCount != uint32(l.values) {
+			return fmt.Errorf("dbc: records of %s have %d fields, but the file has %d", l.record, l.values, t.header.FieldCount)
+		}
+		if !t.sparse && t.header.RecordSize != uint32(l.size) {
+			return fmt.Errorf("dbc: records of %s take %d bytes, but the file has records of %d bytes", l.record, l.size, t.header.RecordSize)
+		}
+		t.storage = make([]storage, len(l.fields))
+		for i, f := range l.fields {
+			s := &l.slots[f.slots[0]]
+			t.storage[i] = storage{offset: s.offset * 8, size: s.size * 8, count: len(f.slots)}
+		}
+	}
+
+	if len(t.storage) != len(l.fields) {
+		return fmt.Errorf("dbc: records of %s have %d fields, but the file has %d", l.record, len(l.fields), len(t.storage))
+	}
+
+	for i := range l.fields {
+		f, s := &l.fields[i], &t.storage[i]
+		count := len(f.slots)
+		is_string := l.slots[f.slots[0]].kind == reflect.String
+
+		switch s.kind {
+		case storage_none:
+			if s.total {
+				if s.size%count != 0 {
+					return fmt.Errorf("dbc: field %s has %d elements, which do not evenly divide its %d bits", f.name, count, s.size)
+				}
+				s.size /= count
+				s.total = false
+			}
+			// Inline strings end with a zero byte rather than having a size
+			if s.size%8 != 0 || s.size > 64 || s.size < 0 || s.size == 0 && !(t.sparse && is_string) {
+				return fmt.Errorf("dbc: field %s has values of %d bits", f.name, s.size)
+			}
+		case storage_bitpacked, storage_bitpacked_signed, storage_pallet:
+			if s.size < 0 || s.size > 64 {
+				return fmt.Errorf("dbc: field %s has values of %d bits", f.name, s.size)
+			}
+			s.count = 1
+		case storage_common:
+			s.count = 1
+		case storage_pallet_array:
+			if s.size < 0 || s.size > 64 {
+				return fmt.Errorf("dbc: field %s has values of %d bits", f.name, s.size)
+			}
+		default:
+			return fmt.Errorf("dbc: field %s has an unknown storage type %d", f.name, s.kind)
+		}
+		if s.count != 0 && s.count != count {
+			return fmt.Errorf("dbc: field %s has %d elements, but the file stores %d", f.name, count, s.count)
+		}
+
+		if slot := &l.slots[f.slots[0]]; is_string {
+			switch {
+			case t.sparse && !slot.inline:
+				return fmt.Errorf("dbc: field %s must be tagged inline, as the file stores strings in its records", f.name)
+			case !t.sparse && slot.inline:
+				return fmt.Errorf("dbc: field %s is tagged inline, but the file stores strings in its string block", f.name)
+			case !t.sparse && s.kind == storage_none && s.size != 32:
+				return fmt.Errorf("dbc: field %s is a string, but the file stores values of %d bits", f.name, s.size)
+			}
+		}
+	}
+	return
+}
+
+// Reads the IDs of records that store them in a field
+func (t *table) find_ids() (err error) {
+	for i := range t.records {
+		r := &t.records[i]
+		if r.has_id || t.id_index >= len(t.storage) {
+			continue
+		}
+
+		var bits uint64
+		if bits, _, _, err = t.storage[t.id_index].read(r, 0); err != nil {
+			return fmt.Errorf("%w in the ID of record %d", err, i)
+		}
+		r.id = uint32(bits)
+		r.has_id = true
+		r.inline_id = true
+	}
+	return
+}
+
+// Adds the records of the copy table
+func (t *table) copy_records() {
+	if len(t.copies) == 0 {
+		return
+	}
+
+	index := make(map[uint32]int, len(t.records))
+	for i := range t.records {
+		index[t.records[i].id] = i
+	}
+	for _, ids := range t.copies {
+		if i, ok := index[ids[1]]; ok {
+			r := t.records[i]
+			r.id = ids[0]
+			r.copied = true
+			t.records = append(t.records, r)
+		}
+	}
+}
+
+// Decodes a record into value
+func (t *table) decode(l *layout, r *record, value reflect.Value) (err error) {
+	if t.sparse {
+		err = t.decode_sparse(l, r, value)
+	} else {
+		err = t.decode_fixed(l, r, value)
+	}
+	if err != nil {
+		return
+	}
+
+	// Records from the copy table differ only in their ID
+	if r.copied && r.inline_id && t.id_index < len(l.fields) {
+		if s := &l.slots[l.fields[t.id_index].slots[0]]; is_integer(s.kind) {
+			s.set(value, uint64(r.id), 32, false)
+		}
+	}
+	if l.id != nil {
+		l.id.set(value, uint64(r.id), 32, false)
+	}
+	if l.relation != nil {
+		l.relation.set(value, uint64(r.relation), 32, false)
+	}
+	return
+}
+
+// Decodes a record whose values are at the positions given by the field storage
+func (t *table) decode_fixed(l *layout, r *record, value reflect.Value) (err error) {
+	for i := range l.slots {
+		s := &l.slots[i]
+		if s.path == nil {
+			continue
+		}
+
+		field := &t.storage[s.field]
+		var (
+			bits   uint64
+			width  int
+			signed bool
+		)
+		if bits, width, signed, err = field.read(r, s.element); err != nil {
+			return fmt.Errorf("%w in field %s", err, l.fields[s.field].name)
+		}
+
+		if s.kind != reflect.String {
+			s.set(value, bits, width, signed)
+			continue
+		}
+
+		offset := int64(bits)
+		if t.relative {
+			offset += int64(r.position + (field.offset+s.element*field.size)/8 - t.records_size)
+		}
+		var str string
+		if str, err = t.strings.lookup(offset); err != nil {
+			return fmt.Errorf("%w in field %s", err, l.fields[s.field].name)
+		}
+		s.value(value, true).SetString(str)
+	}
+	return
+}
+
+// Decodes a record whose values follow one another, with strings stored inline
+func (t *table) decode_sparse(l *layout, r *record, value reflect.Value) (err error) {
+	data := r.data
+	for i := range l.slots {
+		s := &l.slots[i]
+		if s.path == nil {
+			continue
+		}
+
+		if s.kind == reflect.String {
+			end := bytes.IndexByte(data, 0)
+			if end < 0 {
+				return fmt.Errorf("dbc: string in field %s is not terminated", l.fields[s.field].name)
+			}
+			s.value(value, true).SetString(string(data[:end]))
+			data = data[end+1:]
+			continue
+		}
+
+		size := t.storage[s.field].size / 8
+		if size > len(data) {
+			return fmt.Errorf("dbc: value is outside of its record in field %s", l.fields[s.field].name)
+		}
+		s.set(value, read_uint(data, size), size*8, true)
+		data = data[size:]
+	}
+	return
+}
+
+// Reads little-endian values from a file, remembering if it was too short
+type reader struct {
+	data   []byte
+	offset int
+	short  bool
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || n > len(r.data)-r.offset {
+		r.short = true
+		r.offset = len(r.data)
+		return nil
+	}
+	b := r.data[r.offset : r.offset+n : r.offset+n]
+	r.offset += n
+	return b
+}
+
+func (r *reader) seek(offset int) {
+	if offset < 0 || offset > len(r.data) {
+		r.short = true
+		offset = len(r.data)
+	}
+	r.offset = offset
+}
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
@@ -1,434 +1,561 @@
-package text
-
-import (
-	"bufio"
-	"fmt"
-	"io"
-	"reflect"
-	"strconv"
-
-	"github.com/davecgh/go-spew/spew"
-)
-
-// Word describes custom data types that use one string.
-// Useful for custom integral types
-type Word interface {
-	EncodeWord() (string, error)
-	DecodeWord(data string) error
-}
-
-var (
-	word_type = reflect.TypeFor[Word]()
-)
-
-// A Decoder reads and decodes text values from an input stream.
-type Decoder struct {
-	input           *bufio.Reader
-	coding_is_known bool
-	tabular         bool
-	line, column    int
-	peeked_tokens   []*token
-	columns         []string
-}
-
-// NewDecoder returns a new decoder that reads from r.
-// The decoder introduces its own buffering and may read data from r beyond the text values requested.
-func NewDecoder(in io.Reader) *Decoder {
-	return &Decoder{
-		input:  bufio.NewReader(in),
-		line:   1,
-		column: 1,
-	}
-}
-
-// Get the size in bits of a numeric type kind
-func bit_size(t reflect.Kind) int {
-	switch t {
-	case reflect.Uint, reflect.Uint64, reflect.Int, reflect.Int64:
-		return 64
-	case reflect.Uint32, reflect.Int32:
-		return 32
-	case reflect.Uint16, reflect.Int16:
-		return 16
-	case reflect.Uint8, reflect.Int8:
-		return 8
-	case reflect.Float32:
-		return 32
-	case reflect.Float64:
-		return 64
-	default:
-		panic(t)
-	}
-}
-
-// Initializes the value of types that need to be before they are used
-func initialize_value(value reflect.Value) {
-	switch value.Kind() {
-	case reflect.Map:
-		value.Set(reflect.MakeMap(value.Type()))
-	}
-}
-
-func (decoder *Decoder) decode_int(value reflect.Value) (err error) {
-	var (
-		int_token *token
-		i         int64
-	)
-	int_token, err = decoder.next_word()
-	if err != nil {
-		return
-	}
-
-	i, err = strconv.ParseInt(int_token.Data, 0, bit_size(value.Kind()))
-	if err != nil {
-		return
-	}
-
-	value.SetInt(i)
-	return
-}
-
-func (decoder *Decoder) decode_uint(value reflect.Value) (err error) {
-	var (
-		uint_token *token
-		u          uint64
-	)
-	uint_token, err = decoder.next_word()
-	if err != nil {
-		return
-	}
-
-	u, err = strconv.ParseUint(uint_token.Data, 0, bit_size(value.Kind()))
-	if err != nil {
-		return
-	}
-
-	value.SetUint(u)
-	return
-}
-
-func (decoder *Decoder) decode_float(value reflect.Value) (err error) {
-	var (
-		float_token *token
-		f           float64
-	)
-	float_token, err = decoder.next_word()
-	if err != nil {
-		return
-	}
-	f, err = strconv.ParseFloat(float_token.Data, bit_size(value.Kind()))
-	if err != nil {
-		return err
-	}
-	value.SetFloat(f)
-	return
-}
-
-func (decoder *Decoder) decode_bool(value reflect.Value) (err error) {
-	var (
-		boolean_token *token
-		b             bool
-	)
-	boolean_token, err = decoder.next_word()
-	if err != nil {
-		err = fmt.Errorf("error getting boolean word token: %w", err)
-		return
-	}
-	b, err = strconv.ParseBool(boolean_token.Data)
-	if err != nil {
-		return
-	}
-	value.SetBool(b)
-	return
-}
-
-func (decoder *Decoder) decode_string(value reflect.Value) (err error) {
-	var (
-		string_token *token
-	)
-	string_token, err = decoder.next_word()
-	if err != nil {
-		return err
-	}
-	value.SetString(string_token.Data)
-	return nil
-}
-
-func (decoder *Decoder) decode_array(value reflect.Value) (err error) {
-	var (
-		open_token  *token
-		next_token  *token
-		close_token *token
-	)
-	open_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if open_token.Type != token_open {
-		return fmt.Errorf("invalid token at start of array: %d", open_token.Type)
-	}
-
-	for i := range value.Len() {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return
-		}
-		if next_token.Type == token_close {
-			break
-		}
-		err = decoder.decode_value(value.Index(i))
-		if err != nil {
-			return err
-		}
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("array in text appears to exceed bounds")
-	}
-
-	return
-}
-
-func (decoder *Decoder) decode_slice(value reflect.Value) (err error) {
-	var (
-		open_token    *token
-		next_token    *token
-		close_token   *token
-		slice_element reflect.Value
-	)
-	open_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("invalid token at start of slice: %d", open_token.Type)
-		return
-	}
-
-	for {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return err
-		}
-		if next_token.Type == token_close {
-			break
-		}
-		// element must be allocated
-		slice_element = reflect.New(value.Type().Elem()).Elem()
-		err = decoder.decode_value(slice_element)
-		if err != nil {
-			return
-		}
-		value.Set(reflect.Append(value, slice_element))
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("slice does not end with close token?")
-	}
-
-	return
-}
-
-func (decoder *Decoder) decode_map(value reflect.Value) (err error) {
-	var (
-		open_token  *token
-		next_token  *token
-		close_token *token
-	)
-
-	open_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("invalid token at start of Map: %d: %s", open_token.Type, open_token.Data)
-		return
-	}
-
-	value.Set(reflect.MakeMap(value.Type()))
-
-	for {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return err
-		}
-		if next_token.Type == token_close {
-			break
-		}
-
-		key_value := reflect.New(value.Type().Key()).Elem()
-
-		if err := decoder.decode_value(key_value); err != nil {
-			return err
-		}
-
-		map_value := reflect.New(value.Type().Elem()).Elem()
-
-		if err := decoder.decode_value(map_value); err != nil {
-			return err
-		}
-
-		value.SetMapIndex(key_value, map_value)
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("map does not end with close token?")
-	}
-
-	return
-}
-
-func (decoder *Decoder) decode_struct(value reflect.Value) (err error) {
-	var (
-		open_token *token
-		next_token *token
-	)
-
-	set_fields := make(map[string]bool, value.Type().NumField())
-
-	open_token, err = decoder.next_token()
-	if err != nil {
-		err = fmt.Errorf("error in getting open token: %w", err)
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("struct needs open tag")
-		return
-	}
-
-	for {
-		next_token, err = decoder.next_token()
-		if err != nil {
-			err = fmt.Errorf("error in next_token: %w", err)
-			return
-		}
-
-		if next_token.Type == token_close {
-			break
-		}
-
-		if next_token.Type != token_word {
-			err = fmt.Errorf("non-word token as struct key %d", next_token.Type)
-			return
-		}
-
-		field_name := next_token.Data
-
-		if field_name == "" {
-			err = fmt.Errorf("empty keyword in struct")
-			return
-		}
-
-		field := value.FieldByName(field_name)
-
-		if !field.IsValid() {
-			return fmt.Errorf("no field by the name of %s", spew.Sdump(field_name))
-		}
-
-		err = decoder.decode_value(field)
-		if err != nil {
-			err = fmt.Errorf("error in decode_value: %w", err)
-			return
-		}
-
-		if set_fields[field_name] {
-			return fmt.Errorf("field %s already set", field_name)
-		}
-
-		set_fields[field_name] = true
-	}
-	return
-}
-
-// Consumes a text value from the buffered input stream and decodes it into value
-func (decoder *Decoder) decode_value(value reflect.Value) (err error) {
-	if can_encode_word(value) {
-		var (
-			word       Word
-			word_token *token
-		)
-		if reflect.PointerTo(value.Type()).Implements(word_type) {
-			word = value.Addr().Interface().(Word)
-		} else if value.Type().Implements(word_type) {
-			word = value.Interface().(Word)
-		}
-		initialize_value(value)
-		word_token, err = decoder.next_word()
-		if err != nil {
-			return
-		}
-
-		return word.DecodeWord(word_token.Data)
-	}
-
-	switch value.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return decoder.decode_int(value)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return decoder.decode_uint(value)
-	case reflect.Float32, reflect.Float64:
-		return decoder.decode_float(value)
-	case reflect.Bool:
-		return decoder.decode_bool(value)
-	case reflect.String:
-		return decoder.decode_string(value)
-	case reflect.Array:
-		return decoder.decode_array(value)
-	case reflect.Slice:
-		return decoder.decode_slice(value)
-	case reflect.Struct:
-		return decoder.decode_struct(value)
-	case reflect.Map:
-		return decoder.decode_map(value)
-	default:
-	}
-
-	return fmt.Errorf("unknown kind for %s", value.Kind())
-}
-
-func (decoder *Decoder) Decode(value any) (err error) {
-	if !decoder.coding_is_known {
-		var first_token *token
-		first_token, err = decoder.peek_token()
-		if err == nil {
-			if first_token.Type == token_open_table_header {
-				decoder.tabular = true
-				err = decoder.read_table_header()
-				if err != nil {
-					return
-				}
-			}
-		}
-		decoder.coding_is_known = true
-	}
-
-	v := reflect.ValueOf(value)
-	if v.Kind() == reflect.Pointer {
-		v = v.Elem()
-	}
-	v.Set(reflect.New(v.Type()).Elem())
-	if decoder.tabular {
-		return decoder.decode_row(v)
-	} else {
-		return decoder.decode_value(v)
-	}
-}
+package text
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Word describes custom data types that use one string.
+// Useful for custom integral types
+type Word interface {
+	EncodeWord() (string, error)
+	DecodeWord(data string) error
+}
+
+var (
+	word_type = reflect.TypeFor[Word]()
+)
+
+// A Decoder reads and decodes text values from an input stream.
+type Decoder struct {
+	input io.Reader
+	// The input that has been read: buffer[cursor:] has not been consumed yet,
+	// and buffer[0] is at offset base in the input
+	buffer []byte
+	cursor int
+	base   int64
+	// The error that ended the input, such as io.EOF
+	input_err error
+
+	// Set once a table header has been read
+	tabular bool
+	// The position of the next unread character in the input
+	pos position
+	// The position of the last consumed token
+	last position
+	// Set while a top-level value is being decoded
+	in_value bool
+	path     []path_element
+	// The token returned by peek, if has_peeked is set
+	peeked     token
+	has_peeked bool
+	columns    []string
+	// The type of each column in a typed table header, or "" if it has none
+	column_types []string
+	// The columns resolved to the fields of the last row type
+	plan *row_plan
+	// The columns named by SelectColumns, or nil to decode every column
+	selected_columns []string
+	// Keys and columns with no corresponding field, reported at the end of Decode
+	unknown_fields []UnknownField
+	// Set while the input from capture_start onwards is kept in the buffer
+	capturing     bool
+	capture_start int64
+
+	// If true, keys and columns that have no corresponding struct field are skipped.
+	// Otherwise Decode returns an *UnknownFieldError listing all of them.
+	// In either case, they are kept by a field with the "unknown" tag option if there is one.
+	SkipUnknownFields bool
+	// If true, Token and PeekToken also return comments
+	EmitComments bool
+}
+
+// NewDecoder returns a new decoder that reads from r.
+// The decoder introduces its own buffering and may read data from r beyond the text values requested.
+func NewDecoder(in io.Reader) *Decoder {
+	return &Decoder{
+		input: in,
+		pos: position{
+			line:   1,
+			column: 1,
+		},
+	}
+}
+
+// Get the size in bits of a numeric type kind
+func bit_size(t reflect.Kind) int {
+	switch t {
+	case reflect.Uint, reflect.Uint64, reflect.Int, reflect.Int64:
+		return 64
+	case reflect.Uint32, reflect.Int32:
+		return 32
+	case reflect.Uint16, reflect.Int16:
+		return 16
+	case reflect.Uint8, reflect.Int8:
+		return 8
+	case reflect.Float32:
+		return 32
+	case reflect.Float64:
+		return 64
+	default:
+		panic(t)
+	}
+}
+
+// Initializes the value of types that need to be before they are used
+func initialize_value(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Map:
+		value.Set(reflect.MakeMap(value.Type()))
+	}
+}
+
+func (decoder *Decoder) decode_int(value reflect.Value) (err error) {
+	var (
+		int_token token
+		i         int64
+	)
+	int_token, err = decoder.next_word()
+	if err != nil {
+		return
+	}
+
+	i, err = strconv.ParseInt(string(int_token.Data), 0, bit_size(value.Kind()))
+	if err != nil {
+		return
+	}
+
+	value.SetInt(i)
+	return
+}
+
+func (decoder *Decoder) decode_uint(value reflect.Value) (err error) {
+	var (
+		uint_token token
+		u          uint64
+	)
+	uint_token, err = decoder.next_word()
+	if err != nil {
+		return
+	}
+
+	u, err = strconv.ParseUint(string(uint_token.Data), 0, bit_size(value.Kind()))
+	if err != nil {
+		return
+	}
+
+	value.SetUint(u)
+	return
+}
+
+func (decoder *Decoder) decode_float(value reflect.Value) (err error) {
+	var (
+		float_token token
+		f           float64
+	)
+	float_token, err = decoder.next_word()
+	if err != nil {
+		return
+	}
+	f, err = strconv.ParseFloat(string(float_token.Data), bit_size(value.Kind()))
+	if err != nil {
+		return err
+	}
+	value.SetFloat(f)
+	return
+}
+
+func (decoder *Decoder) decode_bool(value reflect.Value) (err error) {
+	var (
+		boolean_token token
+		b             bool
+	)
+	boolean_token, err = decoder.next_word()
+	if err != nil {
+		err = fmt.Errorf("error getting boolean word token: %w", err)
+		return
+	}
+	b, err = strconv.ParseBool(string(boolean_token.Data))
+	if err != nil {
+		return
+	}
+	value.SetBool(b)
+	return
+}
+
+func (decoder *Decoder) decode_string(value reflect.Value) (err error) {
+	var (
+		string_token token
+	)
+	string_token, err = decoder.next_word()
+	if err != nil {
+		return err
+	}
+	value.SetString(string(string_token.Data))
+	return nil
+}
+
+func (decoder *Decoder) decode_array(value reflect.Value) (err error) {
+	var (
+		next_token  token
+		close_token token
+	)
+	_, err = decoder.expect_token(token_open, "at start of array")
+	if err != nil {
+		return
+	}
+
+	for i := range value.Len() {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return
+		}
+		if next_token.Type == token_close {
+			break
+		}
+		decoder.push_index(i)
+		err = decoder.decode_value(value.Index(i))
+		if err != nil {
+			return err
+		}
+		decoder.pop_path()
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "array has more than %d elements", value.Len())
+	}
+
+	return
+}
+
+func (decoder *Decoder) decode_slice(value reflect.Value) (err error) {
+	var (
+		next_token    token
+		close_token   token
+		slice_element reflect.Value
+	)
+	_, err = decoder.expect_token(token_open, "at start of slice")
+	if err != nil {
+		return
+	}
+
+	for i := 0; ; i++ {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return err
+		}
+		if next_token.Type == token_close {
+			break
+		}
+		// element must be allocated
+		slice_element = reflect.New(value.Type().Elem()).Elem()
+		decoder.push_index(i)
+		err = decoder.decode_value(slice_element)
+		if err != nil {
+			return
+		}
+		decoder.pop_path()
+		value.Set(reflect.Append(value, slice_element))
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of slice, found %s", close_token)
+	}
+
+	return
+}
+
+func (decoder *Decoder) decode_map(value reflect.Value) (err error) {
+	var (
+		next_token  token
+		close_token token
+	)
+
+	_, err = decoder.expect_token(token_open, "at start of map")
+	if err != nil {
+		return
+	}
+
+	value.Set(reflect.MakeMap(value.Type()))
+
+	for {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return err
+		}
+		if next_token.Type == token_close {
+			break
+		}
+
+		key_value := reflect.New(value.Type().Key()).Elem()
+
+		word := key_word(key_value.Type(), next_token)
+		if err := decoder.decode_value(key_value); err != nil {
+			return err
+		}
+
+		map_value := reflect.New(value.Type().Elem()).Elem()
+
+		decoder.push_key(key_name(key_value, word))
+		if err := decoder.decode_value(map_value); err != nil {
+			return err
+		}
+		decoder.pop_path()
+
+		value.SetMapIndex(key_value, map_value)
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of map, found %s", close_token)
+	}
+
+	return
+}
+
+func (decoder *Decoder) decode_struct(value reflect.Value) (err error) {
+	var (
+		next_token token
+	)
+
+	var fields *struct_fields
+	if fields, err = get_struct_fields(value.Type()); err != nil {
+		return
+	}
+
+	// A bit for each field that has been set
+	var (
+		set_small  [2]uint64
+		set_fields = set_small[:]
+	)
+	if len(fields.list) > 64*len(set_small) {
+		set_fields = make([]uint64, (len(fields.list)+63)/64)
+	}
+
+	_, err = decoder.expect_token(token_open, "at start of struct")
+	if err != nil {
+		return
+	}
+
+	for {
+		next_token, err = decoder.next_token()
+		if err != nil {
+			return
+		}
+
+		if next_token.Type == token_close {
+			break
+		}
+
+		if next_token.Type != token_word {
+			err = syntax_error(next_token.pos, "expected a struct key, found %s", next_token)
+			return
+		}
+
+		if len(next_token.Data) == 0 {
+			err = syntax_error(next_token.pos, "empty keyword in struct")
+			return
+		}
+
+		i, ok := fields.by_name[string(next_token.Data)]
+		if !ok {
+			if err = decoder.decode_unknown(value, fields, string(next_token.Data), next_token.pos); err != nil {
+				return
+			}
+			continue
+		}
+
+		struct_field := &fields.list[i]
+		if set_fields[i/64]&(1<<(i%64)) != 0 {
+			return fmt.Errorf("field %s already set", struct_field.name)
+		}
+		set_fields[i/64] |= 1 << (i % 64)
+
+		decoder.push_field(struct_field.name)
+		err = decoder.decode_value(field_value_alloc(value, struct_field))
+		if err != nil {
+			return
+		}
+		decoder.pop_path()
+	}
+	return
+}
+
+// Handles a key or column with no corresponding field, found at pos. The value is skipped,
+// and kept in the struct's catch-all field if it has one.
+func (decoder *Decoder) decode_unknown(value reflect.Value, fields *struct_fields, name string, pos position) (err error) {
+	if fields.unknown == nil {
+		if !decoder.SkipUnknownFields {
+			decoder.unknown_field(name, pos)
+		}
+		_, err = decoder.skip_value(false, true)
+		return
+	}
+
+	var raw RawValue
+	raw, err = decoder.skip_value(true, true)
+	if err != nil {
+		return
+	}
+
+	unknown := field_value_alloc(value, fields.unknown)
+	if unknown.IsNil() {
+		unknown.Set(reflect.MakeMap(unknown.Type()))
+	}
+	unknown.SetMapIndex(reflect.ValueOf(name).Convert(unknown.Type().Key()), reflect.ValueOf(raw))
+	return
+}
+
+// Decodes a pointer. The word nil sets the pointer to nil, otherwise
+// the pointer is allocated if needed and decode is called on the element.
+func (decoder *Decoder) decode_pointer(value reflect.Value, decode func(reflect.Value) error) (err error) {
+	var next_token token
+	next_token, err = decoder.peek_token()
+	if err != nil {
+		return
+	}
+
+	if next_token.is_nil() {
+		if _, err = decoder.next_token(); err != nil {
+			return
+		}
+		value.Set(reflect.Zero(value.Type()))
+		return
+	}
+
+	if value.IsNil() {
+		value.Set(reflect.New(value.Type().Elem()))
+	}
+
+	return decode(value.Elem())
+}
+
+// Consumes a text value from the buffered input stream and decodes it into value
+func (decoder *Decoder) decode_value(value reflect.Value) (err error) {
+	if value.Kind() == reflect.Pointer {
+		return decoder.decode_pointer(value, decoder.decode_value)
+	}
+
+	if value.Kind() == reflect.Interface {
+		return decoder.decode_interface(value, false)
+	}
+
+	if value.Type() == raw_value_type {
+		return decoder.decode_raw(value)
+	}
+
+	if can_unmarshal_block(value) {
+		return decoder.decode_block(value, false)
+	}
+
+	if can_decode_word(value) {
+		return decoder.decode_word(value)
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return decoder.decode_int(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return decoder.decode_uint(value)
+	case reflect.Float32, reflect.Float64:
+		return decoder.decode_float(value)
+	case reflect.Bool:
+		return decoder.decode_bool(value)
+	case reflect.String:
+		return decoder.decode_string(value)
+	case reflect.Array:
+		return decoder.decode_array(value)
+	case reflect.Slice:
+		return decoder.decode_slice(value)
+	case reflect.Struct:
+		return decoder.decode_struct(value)
+	case reflect.Map:
+		return decoder.decode_map(value)
+	default:
+	}
+
+	return fmt.Errorf("unknown kind for %s", value.Kind())
+}
+
+// Decode reads the next text value (or table row) from its input and stores it in the value pointed to by value.
+// Decoding into an empty interface stores a generic value, as described for Table.
+// At the end of the input, Decode returns an error wrapping io.EOF.
+// Malformed input is reported as a *DecodeError, which may wrap a *SyntaxError.
+func (decoder *Decoder) Decode(value any) (err error) {
+	defer func() {
+		decoder.in_value = false
+		decoder.capturing = false
+		err = decoder.decode_error(err)
+	}()
+
+	var first_token token
+	first_token, err = decoder.peek_token()
+	if err != nil {
+		return
+	}
+
+	decoder.path = decoder.path[:0]
+	decoder.unknown_fields = decoder.unknown_fields[:0]
+
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	// A table header may begin a new table between any two values
+	for first_token.Type == token_open_table_header {
+		if err = decoder.begin_table(); err != nil {
+			return
+		}
+
+		// The whole table is decoded as a generic table
+		if is_table_value(v) {
+			return decoder.decode_table(v)
+		}
+
+		// A table may have no rows
+		if first_token, err = decoder.peek_token(); err != nil {
+			return
+		}
+	}
+
+	if v.Type() == table_type {
+		return decoder.decode_table(v)
+	}
+
+	decoder.in_value = true
+
+	v.SetZero()
+	if decoder.tabular {
+		if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+			// Rows are decoded one at a time once the header has been read
+			var row []any
+			if row, err = decoder.read_generic_row(); err == nil {
+				v.Set(reflect.ValueOf(row))
+			}
+		} else if v.Type() == raw_row_type {
+			var row []RawValue
+			if row, err = decoder.read_raw_row(); err == nil {
+				v.Set(reflect.ValueOf(row))
+			}
+		} else {
+			err = decoder.decode_row(v)
+		}
+	} else {
+		err = decoder.decode_value(v)
+	}
+
+	if err == nil && len(decoder.unknown_fields) > 0 {
+		err = &UnknownFieldError{
+			Fields: slices.Clone(decoder.unknown_fields),
+		}
+	}
+	return
+}
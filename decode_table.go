@@ -1,349 +1,437 @@
-package text
-
-import (
-	"fmt"
-	"reflect"
-
-	"github.com/davecgh/go-spew/spew"
-)
-
-func (decoder *Decoder) decode_map_column(value reflect.Value) (err error) {
-	var (
-		open_token  *token
-		next_token  *token
-		close_token *token
-	)
-
-	open_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("invalid token at start of Map: %d: %s", open_token.Type, open_token.Data)
-		return
-	}
-
-	value.Set(reflect.MakeMap(value.Type()))
-
-	for {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return err
-		}
-		if next_token.Type == token_close {
-			break
-		}
-
-		key_value := reflect.New(value.Type().Key()).Elem()
-
-		if err := decoder.decode_value(key_value); err != nil {
-			return err
-		}
-
-		map_value := reflect.New(value.Type().Elem()).Elem()
-
-		if err := decoder.decode_column(map_value); err != nil {
-			return err
-		}
-
-		value.SetMapIndex(key_value, map_value)
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("map does not end with close token?")
-	}
-
-	return
-}
-
-func (decoder *Decoder) decode_array_column(value reflect.Value) (err error) {
-	var (
-		open_token  *token
-		next_token  *token
-		close_token *token
-	)
-	open_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if open_token.Type != token_open {
-		return fmt.Errorf("invalid token at start of array: %d", open_token.Type)
-	}
-
-	for i := range value.Len() {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return
-		}
-		if next_token.Type == token_close {
-			break
-		}
-		err = decoder.decode_column(value.Index(i))
-		if err != nil {
-			return err
-		}
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("array in text appears to exceed bounds")
-	}
-
-	return
-}
-
-func (decoder *Decoder) decode_slice_column(value reflect.Value) (err error) {
-	var (
-		open_token    *token
-		next_token    *token
-		close_token   *token
-		slice_element reflect.Value
-	)
-	open_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("invalid token at start of slice: %d", open_token.Type)
-		return
-	}
-
-	for {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return err
-		}
-		if next_token.Type == token_close {
-			break
-		}
-		// element must be allocated
-		slice_element = reflect.New(value.Type().Elem()).Elem()
-		err = decoder.decode_column(slice_element)
-		if err != nil {
-			return
-		}
-		value.Set(reflect.Append(value, slice_element))
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("slice does not end with close token?")
-	}
-
-	return
-}
-
-func (decoder *Decoder) read_table_header() (err error) {
-	var (
-		t *token
-	)
-	t, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	if t.Type != token_open_table_header {
-		err = fmt.Errorf("table header is invalid")
-		return
-	}
-
-	for {
-		t, err = decoder.next_token()
-		if err != nil {
-			return
-		}
-
-		switch t.Type {
-		case token_word:
-			decoder.columns = append(decoder.columns, t.Data)
-		case token_close_table_header:
-			return
-		default:
-			err = fmt.Errorf("invalid token %d in table header", t.Type)
-			return
-		}
-	}
-}
-
-func (decoder *Decoder) decode_column(value reflect.Value) (err error) {
-	if can_encode_word(value) {
-		var (
-			word       Word
-			word_token *token
-		)
-		if reflect.PointerTo(value.Type()).Implements(word_type) {
-			word = value.Addr().Interface().(Word)
-		} else if value.Type().Implements(word_type) {
-			word = value.Interface().(Word)
-		}
-		initialize_value(value)
-		word_token, err = decoder.next_word()
-		if err != nil {
-			return
-		}
-
-		return word.DecodeWord(word_token.Data)
-	}
-
-	switch value.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return decoder.decode_int(value)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return decoder.decode_uint(value)
-	case reflect.Float32, reflect.Float64:
-		return decoder.decode_float(value)
-	case reflect.Bool:
-		return decoder.decode_bool(value)
-	case reflect.String:
-		return decoder.decode_string(value)
-	case reflect.Array:
-		return decoder.decode_array_column(value)
-	case reflect.Slice:
-		return decoder.decode_slice_column(value)
-	case reflect.Struct:
-		return decoder.decode_unkeyed_struct(value)
-	case reflect.Map:
-		return decoder.decode_map_column(value)
-	default:
-	}
-
-	return fmt.Errorf("unknown kind for %s", value.Kind())
-}
-
-func (decoder *Decoder) decode_unkeyed_struct(value reflect.Value) (err error) {
-	var (
-		open_token  *token
-		close_token *token
-		next_token  *token
-	)
-
-	open_token, err = decoder.next_token()
-	if err != nil {
-		err = fmt.Errorf("error in getting open token: %w", err)
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("struct needs open tag (tag is %d, '%s')", open_token.Type, open_token.Data)
-		return
-	}
-
-	for i := 0; ; i++ {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return
-		}
-
-		if next_token.Type == token_close {
-			break
-		}
-
-		if i >= value.Type().NumField() {
-			err = fmt.Errorf("value [#%d] in row at line %d column %d exceeds the number of columns in table header", i, decoder.line, decoder.column)
-			return
-		}
-
-		field := value.Field(i)
-
-		if !field.IsValid() {
-			return fmt.Errorf("no field in %s at the index %d", value.Type(), i)
-		}
-
-		err = decoder.decode_value(field)
-		if err != nil {
-			err = fmt.Errorf("error in decode_value: %w", err)
-			return
-		}
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		err = fmt.Errorf("error in getting open token: %w", err)
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("struct needs close tag")
-		return
-	}
-
-	return
-}
-
-func (decoder *Decoder) decode_row(value reflect.Value) (err error) {
-	var (
-		open_token  *token
-		next_token  *token
-		close_token *token
-	)
-
-	open_token, err = decoder.next_token()
-	if err != nil {
-		err = fmt.Errorf("error in getting open token: %w", err)
-		return
-	}
-
-	if open_token.Type != token_open {
-		err = fmt.Errorf("struct needs open tag (tag type is %d, '%s')", open_token.Type, open_token.Data)
-		return
-	}
-
-	for i := 0; ; i++ {
-		next_token, err = decoder.peek_token()
-		if err != nil {
-			return
-		}
-
-		if next_token.Type == token_close {
-			break
-		}
-
-		if i >= len(decoder.columns) {
-			err = fmt.Errorf("value [#%d] in row at line %d exceeds the number of columns in table header", i, decoder.line)
-			return
-		}
-
-		field_name := decoder.columns[i]
-
-		field := value.FieldByName(field_name)
-
-		if !field.IsValid() {
-			return fmt.Errorf("no field by the name of %s", spew.Sdump(field_name))
-		}
-
-		err = decoder.decode_value(field)
-		if err != nil {
-			err = fmt.Errorf("error in decode_value: %w", err)
-			return
-		}
-	}
-
-	close_token, err = decoder.next_token()
-	if err != nil {
-		err = fmt.Errorf("error in getting open token: %w", err)
-		return
-	}
-
-	if close_token.Type != token_close {
-		err = fmt.Errorf("struct needs close tag")
-		return
-	}
-
-	return
-}
+package text
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+)
+
+func (decoder *Decoder) decode_map_column(value reflect.Value) (err error) {
+	var (
+		next_token  token
+		close_token token
+	)
+
+	_, err = decoder.expect_token(token_open, "at start of map")
+	if err != nil {
+		return
+	}
+
+	value.Set(reflect.MakeMap(value.Type()))
+
+	for {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return err
+		}
+		if next_token.Type == token_close {
+			break
+		}
+
+		key_value := reflect.New(value.Type().Key()).Elem()
+
+		word := key_word(key_value.Type(), next_token)
+		if err := decoder.decode_value(key_value); err != nil {
+			return err
+		}
+
+		map_value := reflect.New(value.Type().Elem()).Elem()
+
+		decoder.push_key(key_name(key_value, word))
+		if err := decoder.decode_column(map_value); err != nil {
+			return err
+		}
+		decoder.pop_path()
+
+		value.SetMapIndex(key_value, map_value)
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of map, found %s", close_token)
+	}
+
+	return
+}
+
+func (decoder *Decoder) decode_array_column(value reflect.Value) (err error) {
+	var (
+		next_token  token
+		close_token token
+	)
+	_, err = decoder.expect_token(token_open, "at start of array")
+	if err != nil {
+		return
+	}
+
+	for i := range value.Len() {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return
+		}
+		if next_token.Type == token_close {
+			break
+		}
+		decoder.push_index(i)
+		err = decoder.decode_column(value.Index(i))
+		if err != nil {
+			return err
+		}
+		decoder.pop_path()
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "array has more than %d elements", value.Len())
+	}
+
+	return
+}
+
+func (decoder *Decoder) decode_slice_column(value reflect.Value) (err error) {
+	var (
+		next_token    token
+		close_token   token
+		slice_element reflect.Value
+	)
+	_, err = decoder.expect_token(token_open, "at start of slice")
+	if err != nil {
+		return
+	}
+
+	for i := 0; ; i++ {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return err
+		}
+		if next_token.Type == token_close {
+			break
+		}
+		// element must be allocated
+		slice_element = reflect.New(value.Type().Elem()).Elem()
+		decoder.push_index(i)
+		err = decoder.decode_column(slice_element)
+		if err != nil {
+			return
+		}
+		decoder.pop_path()
+		value.Set(reflect.Append(value, slice_element))
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of slice, found %s", close_token)
+	}
+
+	return
+}
+
+// Reads a table header, after which rows are decoded according to its columns
+func (decoder *Decoder) begin_table() (err error) {
+	decoder.tabular = true
+	decoder.in_value = true
+	if err = decoder.read_table_header(); err != nil {
+		return
+	}
+	decoder.in_value = false
+	return
+}
+
+// NextTable reads the header of the next table in the input, skipping any rows of the current table
+// that have not been decoded. Decode reads table headers by itself, so NextTable is only needed to
+// find out where each table of a multi-table stream begins.
+// At the end of the input, NextTable returns an error wrapping io.EOF.
+func (decoder *Decoder) NextTable() (err error) {
+	defer func() {
+		decoder.in_value = false
+		err = decoder.decode_error(err)
+	}()
+
+	decoder.path = decoder.path[:0]
+
+	for {
+		var t token
+		if t, err = decoder.peek_token(); err != nil {
+			return
+		}
+		if t.Type == token_open_table_header {
+			break
+		}
+
+		decoder.in_value = true
+		if _, err = decoder.skip_value(false, true); err != nil {
+			return
+		}
+		decoder.in_value = false
+	}
+
+	return decoder.begin_table()
+}
+
+// More reports whether there is another row in the current table, or another value in input that is
+// not tabular. It returns false at the end of the input, and where the header of another table begins.
+func (decoder *Decoder) More() bool {
+	t, err := decoder.peek_token()
+	if err != nil {
+		// Other errors are returned by the next call to Decode
+		return !errors.Is(err, io.EOF)
+	}
+
+	return t.Type != token_open_table_header || !decoder.tabular
+}
+
+// SelectColumns limits the columns of table rows that Decode stores in a struct to the ones named.
+// The values of other columns are skipped without being decoded, and are not reported as unknown fields.
+// Calling SelectColumns with no names decodes every column again.
+func (decoder *Decoder) SelectColumns(names ...string) {
+	decoder.selected_columns = nil
+	if len(names) > 0 {
+		decoder.selected_columns = slices.Clone(names)
+	}
+	decoder.plan = nil
+}
+
+// Columns returns the column names of the current table header, or nil if no table header has been read.
+func (decoder *Decoder) Columns() []string {
+	if !decoder.tabular {
+		return nil
+	}
+	return slices.Clone(decoder.columns)
+}
+
+// ColumnTypes returns the type of each column in the current table header, or nil if the header is not typed.
+func (decoder *Decoder) ColumnTypes() []string {
+	for _, column_type := range decoder.column_types {
+		if column_type != "" {
+			return slices.Clone(decoder.column_types)
+		}
+	}
+	return nil
+}
+
+func (decoder *Decoder) read_table_header() (err error) {
+	var (
+		t token
+	)
+	_, err = decoder.expect_token(token_open_table_header, "at start of table header")
+	if err != nil {
+		return
+	}
+
+	decoder.columns = decoder.columns[:0]
+	decoder.column_types = decoder.column_types[:0]
+	decoder.plan = nil
+
+	for {
+		t, err = decoder.next_token()
+		if err != nil {
+			return
+		}
+
+		switch t.Type {
+		case token_word:
+			name, column_type := split_typed_column(string(t.Data))
+			decoder.columns = append(decoder.columns, name)
+			decoder.column_types = append(decoder.column_types, column_type)
+		case token_close_table_header:
+			return
+		default:
+			err = syntax_error(t.pos, "expected a column name in table header, found %s", t)
+			return
+		}
+	}
+}
+
+func (decoder *Decoder) decode_column(value reflect.Value) (err error) {
+	if value.Kind() == reflect.Pointer {
+		return decoder.decode_pointer(value, decoder.decode_column)
+	}
+
+	if value.Kind() == reflect.Interface {
+		return decoder.decode_interface(value, true)
+	}
+
+	if value.Type() == raw_value_type {
+		return decoder.decode_raw(value)
+	}
+
+	if can_unmarshal_block(value) {
+		return decoder.decode_block(value, true)
+	}
+
+	if can_decode_word(value) {
+		return decoder.decode_word(value)
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return decoder.decode_int(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return decoder.decode_uint(value)
+	case reflect.Float32, reflect.Float64:
+		return decoder.decode_float(value)
+	case reflect.Bool:
+		return decoder.decode_bool(value)
+	case reflect.String:
+		return decoder.decode_string(value)
+	case reflect.Array:
+		return decoder.decode_array_column(value)
+	case reflect.Slice:
+		return decoder.decode_slice_column(value)
+	case reflect.Struct:
+		return decoder.decode_unkeyed_struct(value)
+	case reflect.Map:
+		return decoder.decode_map_column(value)
+	default:
+	}
+
+	return fmt.Errorf("unknown kind for %s", value.Kind())
+}
+
+func (decoder *Decoder) decode_unkeyed_struct(value reflect.Value) (err error) {
+	var (
+		close_token token
+		next_token  token
+	)
+
+	_, err = decoder.expect_token(token_open, "at start of struct")
+	if err != nil {
+		return
+	}
+
+	var fields *struct_fields
+	if fields, err = get_struct_fields(value.Type()); err != nil {
+		return
+	}
+
+	for i := 0; ; i++ {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return
+		}
+
+		if next_token.Type == token_close {
+			break
+		}
+
+		if i >= len(fields.list) {
+			err = syntax_error(next_token.pos, "value [#%d] exceeds the number of fields in %s", i, value.Type())
+			return
+		}
+
+		field := field_value_alloc(value, &fields.list[i])
+
+		decoder.push_field(fields.list[i].name)
+		err = decoder.decode_column(field)
+		if err != nil {
+			return
+		}
+		decoder.pop_path()
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of struct, found %s", close_token)
+		return
+	}
+
+	return
+}
+
+func (decoder *Decoder) decode_row(value reflect.Value) (err error) {
+	var (
+		next_token  token
+		close_token token
+	)
+
+	if value.Kind() != reflect.Struct {
+		err = fmt.Errorf("to use tabular decoding, a row must be a struct")
+		return
+	}
+
+	_, err = decoder.expect_token(token_open, "at start of row")
+	if err != nil {
+		return
+	}
+
+	var plan *row_plan
+	if plan, err = decoder.get_row_plan(value.Type()); err != nil {
+		return
+	}
+
+	for i := range plan.defaults {
+		if err = plan.defaults[i].set(value); err != nil {
+			return
+		}
+	}
+
+	for i := 0; ; i++ {
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return
+		}
+
+		if next_token.Type == token_close {
+			break
+		}
+
+		if i >= len(plan.columns) {
+			err = syntax_error(next_token.pos, "value [#%d] in row exceeds the number of columns in table header", i)
+			return
+		}
+
+		if plan.skip[i] {
+			if _, err = decoder.skip_value(false, decoder.is_interface_column(i)); err != nil {
+				return
+			}
+			continue
+		}
+
+		field_name := decoder.columns[i]
+
+		struct_field := plan.columns[i]
+		if struct_field == nil {
+			if err = decoder.decode_unknown(value, plan.fields, field_name, next_token.pos); err != nil {
+				return
+			}
+			continue
+		}
+
+		decoder.push_field(field_name)
+		err = decoder.decode_column(field_value_alloc(value, struct_field))
+		if err != nil {
+			return
+		}
+		decoder.pop_path()
+	}
+
+	close_token, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if close_token.Type != token_close {
+		err = syntax_error(close_token.pos, "expected '}' at end of struct, found %s", close_token)
+		return
+	}
+
+	return
+}
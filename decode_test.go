@@ -1,35 +1,150 @@
-package text_test
-
-import (
-	"errors"
-	"io"
-	"reflect"
-	"strings"
-	"testing"
-
-	"github.com/Gophercraft/text"
-)
-
-func TestDecodeTable(t *testing.T) {
-	for _, case1 := range cases1 {
-		decoder := text.NewDecoder(strings.NewReader(case1.EncodedTable))
-
-		var records []record1
-
-		for {
-			var record record1
-			if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
-				break
-			} else if err != nil {
-				t.Fatal(err)
-			}
-
-			records = append(records, record)
-		}
-
-		if !reflect.DeepEqual(case1.Record1, records) {
-			t.Fatal("got back incorrect records")
-		}
-	}
-
-}
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+func TestDecodeTable(t *testing.T) {
+	for _, case1 := range cases1 {
+		decoder := text.NewDecoder(strings.NewReader(case1.EncodedTable))
+
+		var records []record1
+
+		for {
+			var record record1
+			if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			records = append(records, record)
+		}
+
+		if !reflect.DeepEqual(case1.Record1, records) {
+			t.Fatal("got back incorrect records")
+		}
+	}
+
+}
+
+func TestDecodeMultipleTables(t *testing.T) {
+	input := `[ ID Key ]
+{ 1 A }
+{ 2 B }
+[ Name Level ]
+[ Level Name ]
+{ 60 Thrall }
+`
+
+	// Decode switches to the columns of each new header
+	decoder := text.NewDecoder(strings.NewReader(input))
+	var records []record2
+	for {
+		var record record2
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			// The rows of the first table have no corresponding fields
+			var unknown *text.UnknownFieldError
+			if !errors.As(err, &unknown) {
+				t.Fatal(err)
+			}
+			continue
+		}
+		records = append(records, record)
+	}
+	if !reflect.DeepEqual(records, []record2{{"Thrall", 60}}) {
+		t.Fatal("got back incorrect records", records)
+	}
+
+	// Each table is decoded into its own generic Table
+	decoder = text.NewDecoder(strings.NewReader(input))
+	var tables []text.Table
+	for {
+		var table text.Table
+		if err := decoder.Decode(&table); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+
+	expected := []text.Table{
+		{Columns: []string{"ID", "Key"}, Rows: [][]any{{"1", "A"}, {"2", "B"}}},
+		{Columns: []string{"Name", "Level"}},
+		{Columns: []string{"Level", "Name"}, Rows: [][]any{{"60", "Thrall"}}},
+	}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Fatalf("got back incorrect tables %#v", tables)
+	}
+}
+
+type item_name struct {
+	ID   uint32
+	Name string
+}
+
+type item_id struct {
+	ID uint32
+}
+
+func TestDecodeSelectedColumns(t *testing.T) {
+	var buf bytes.Buffer
+	items := bench_items(200)
+	if err := text.EncodeAll(&buf, items, text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Columns that are not selected are not reported as unknown
+	decoder := text.NewDecoder(bytes.NewReader(buf.Bytes()))
+	decoder.SelectColumns("ID", "Name")
+	for _, item := range items {
+		var name item_name
+		if err := decoder.Decode(&name); err != nil {
+			t.Fatal(err)
+		}
+		if name.ID != item.ID || name.Name != item.Name {
+			t.Fatal("got back incorrect row", name)
+		}
+	}
+
+	// Columns can be left out even when the row type has fields for them
+	decoder = text.NewDecoder(bytes.NewReader(buf.Bytes()))
+	decoder.SelectColumns("Name")
+	var name item_name
+	if err := decoder.Decode(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name.ID != 0 || name.Name != items[0].Name {
+		t.Fatal("got back incorrect row", name)
+	}
+
+	// Skipped columns are not decoded, so nothing is allocated for them
+	decoder.SelectColumns("ID")
+	var id item_id
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := decoder.Decode(&id); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatal("allocations per row:", allocs)
+	}
+
+	rows, err := text.DecodeTableParallel[item_name](bytes.NewReader(buf.Bytes()), text.ParallelOptions{Columns: []string{"ID", "Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(items) || rows[199].Name != items[199].Name {
+		t.Fatal("got back incorrect rows")
+	}
+}
@@ -1,15 +1,92 @@
-package text
-
-import "bytes"
-
-func Marshal(value any) ([]byte, error) {
-	out := new(bytes.Buffer)
-	err := NewEncoder(out).Encode(value)
-	return out.Bytes(), err
-}
-
-func Unmarshal(b []byte, value any) error {
-	in := bytes.NewReader(b)
-	err := NewDecoder(in).Decode(value)
-	return err
-}
+package text
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+)
+
+func Marshal(value any) ([]byte, error) {
+	out := new(bytes.Buffer)
+	encoder := NewEncoder(out)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	err := encoder.Flush()
+	return out.Bytes(), err
+}
+
+func Unmarshal(b []byte, value any) error {
+	in := bytes.NewReader(b)
+	err := NewDecoder(in).Decode(value)
+	return err
+}
+
+// EncodeOptions configure EncodeAll.
+type EncodeOptions struct {
+	// Write the values as the rows of a table
+	Tabular bool
+	// What each indent should be. If empty, this is "\t", or " " between the columns of a table
+	Indent string
+	// Give the type of each column in the table header
+	TypedHeader bool
+}
+
+// EncodeAll writes each value in values to w, as a table if opts.Tabular is set.
+func EncodeAll[T any](w io.Writer, values []T, opts EncodeOptions) (err error) {
+	encoder := NewEncoder(w)
+	encoder.Tabular = opts.Tabular
+	encoder.TypedHeader = opts.TypedHeader
+	switch {
+	case opts.Indent != "":
+		encoder.Indent = opts.Indent
+	case opts.Tabular:
+		encoder.Indent = " "
+	}
+
+	for i := range values {
+		if err = encoder.Encode(&values[i]); err != nil {
+			return
+		}
+	}
+	return encoder.Flush()
+}
+
+// DecodeAll reads every value (or table row) in r.
+func DecodeAll[T any](r io.Reader) (values []T, err error) {
+	decoder := NewDecoder(r)
+	for {
+		var value T
+		if err = decoder.Decode(&value); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return
+		}
+		values = append(values, value)
+	}
+}
+
+// Rows returns an iterator over the rows of the current table, or over the values of input that is not tabular.
+// It stops where the header of another table begins, or at the end of the input.
+// If a value cannot be decoded, the error is yielded and the iteration ends.
+func Rows[T any](decoder *Decoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for decoder.More() {
+			var value T
+			err := decoder.Decode(&value)
+			if errors.Is(err, io.EOF) {
+				// A table with no rows
+				return
+			}
+			if err != nil {
+				yield(value, err)
+				return
+			}
+			if !yield(value, nil) {
+				return
+			}
+		}
+	}
+}
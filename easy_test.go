@@ -0,0 +1,80 @@
+package text_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+func TestEncodeDecodeAll(t *testing.T) {
+	for _, tabular := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := text.EncodeAll(&buf, pointer_records, text.EncodeOptions{Tabular: tabular}); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := text.DecodeAll[pointer_record](&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(records, pointer_records) {
+			t.Fatal("got back incorrect records", records)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := text.EncodeAll(&buf, cases1[0].Record1, text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != cases1[0].EncodedTable {
+		t.Fatal(buf.String(), "should have been equal to", cases1[0].EncodedTable)
+	}
+}
+
+func TestRows(t *testing.T) {
+	input := `[ Name Level ]
+{ Thrall 60 }
+{ Jaina 62 }
+[ ID Key ]
+{ 1 A }
+{ 2 "B }
+`
+
+	decoder := text.NewDecoder(strings.NewReader(input))
+
+	var records []record2
+	for record, err := range text.Rows[record2](decoder) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if !reflect.DeepEqual(records, []record2{{"Thrall", 60}, {"Jaina", 62}}) {
+		t.Fatal("got back incorrect records", records)
+	}
+
+	if err := decoder.NextTable(); err != nil {
+		t.Fatal(err)
+	}
+	var errs int
+	for record, err := range text.Rows[record1](decoder) {
+		if err != nil {
+			errs++
+			continue
+		}
+		if record.ID != 1 || record.Key != "A" {
+			t.Fatal("got back incorrect record", record)
+		}
+	}
+	if errs != 1 {
+		t.Fatal("expected one error, got", errs)
+	}
+
+	// A table with no rows
+	for _, err := range text.Rows[record2](text.NewDecoder(strings.NewReader("[ Name Level ]\n"))) {
+		t.Fatal("expected no rows, got", err)
+	}
+}
@@ -1,275 +1,538 @@
-package text
-
-import (
-	"fmt"
-	"io"
-	"reflect"
-	"sort"
-	"strconv"
-	"strings"
-)
-
-type Encoder struct {
-	// Have I encoded the table header already?
-	wrote_table_header bool
-	out                io.Writer
-	// What each indent should be. Preferably "\t" or "  "
-	Indent string
-
-	// Tabular encoding
-	Tabular bool
-}
-
-func NewEncoder(out io.Writer) *Encoder {
-	return &Encoder{
-		out:    out,
-		Indent: "\t",
-	}
-}
-
-func (encoder *Encoder) writeIndentation(depth int) {
-	for x := 0; x < depth; x++ {
-		if _, err := encoder.out.Write([]byte(encoder.Indent)); err != nil {
-			return
-		}
-	}
-}
-
-func (encoder *Encoder) write_table_header(t reflect.Type) (err error) {
-	encoder.out.Write([]byte("[ "))
-	for i := range t.NumField() {
-		if err = encoder.encode_string(t.Field(i).Name); err != nil {
-			return
-		}
-		encoder.out.Write([]byte(" "))
-	}
-	_, err = encoder.out.Write([]byte("]\n"))
-	return
-}
-
-func (encoder *Encoder) Encode(value any) (err error) {
-	v := reflect.ValueOf(value)
-	if v.Kind() == reflect.Pointer {
-		v = v.Elem()
-	}
-
-	if encoder.Tabular {
-		if encoder.wrote_table_header == false {
-			if err = encoder.write_table_header(v.Type()); err != nil {
-				return
-			}
-		}
-		return encoder.encode_row(v)
-	}
-
-	return encoder.encode_value(0, v)
-}
-
-func encode_string(out io.Writer, str string) error {
-	if str == "" {
-		// an empty word where a value should be can be confusing and/or perilous.
-		_, err := out.Write([]byte(`""`))
-		return err
-	}
-
-	can_encode_without_quotes := !strings.ContainsAny(str, " \n\t\r'\\\"{}[]")
-
-	// Without escape sequences, the string is good to be encoded without quotes.
-	if can_encode_without_quotes {
-		_, err := out.Write([]byte(str))
-		return err
-	}
-
-	str = strings.Replace(str, "\n", "\\n", -1)
-	str = strings.Replace(str, "\t", "\\t", -1)
-	str = strings.Replace(str, "\r", "\\r", -1)
-	str = strings.Replace(str, "\\", "\\\\", -1)
-	str = strings.Replace(str, "\"", "\\\"", -1)
-
-	_, err := out.Write([]byte("\"" + str + "\""))
-	return err
-}
-
-func (encoder *Encoder) encode_string(str string) error {
-	return encode_string(encoder.out, str)
-}
-
-func can_encode_word(field reflect.Value) bool {
-	return reflect.PointerTo(field.Type()).Implements(word_type) || field.Type().Implements(word_type)
-}
-
-func is_bracketed_value(field reflect.Value) bool {
-	return !can_encode_word(field) && (field.Kind() == reflect.Struct || field.Kind() == reflect.Array || field.Kind() == reflect.Slice || field.Kind() == reflect.Map)
-}
-
-func (encoder *Encoder) encode_word(value reflect.Value) (err error) {
-	var str string
-	// Maps, etc already act like pointers
-	if value.Type().Implements(word_type) {
-		str, err = value.Interface().(Word).EncodeWord()
-		// Use pointer receiver methods
-	} else if reflect.PointerTo(value.Type()).Implements(word_type) {
-		if value.CanAddr() {
-			str, err = value.Addr().Interface().(Word).EncodeWord()
-		} else {
-			// This value is not addressable, but needs to be used as a pointer receiver. This is a bit of a problem.
-			// This happens when map key values are used
-			// The best we can do is dupe the value.
-			newAlloc := reflect.New(value.Type())
-			newAlloc.Elem().Set(value)
-			str, err = newAlloc.Interface().(Word).EncodeWord()
-		}
-	}
-	if err != nil {
-		return
-	}
-
-	err = encoder.encode_string(str)
-	return
-}
-
-func (encoder *Encoder) encode_value(depth int, value reflect.Value) error {
-	encoder.writeIndentation(depth)
-
-	if can_encode_word(value) {
-		return encoder.encode_word(value)
-	}
-
-	switch value.Kind() {
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if _, err := encoder.out.Write([]byte(strconv.FormatUint(value.Uint(), 10))); err != nil {
-			return err
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if _, err := encoder.out.Write([]byte(strconv.FormatInt(value.Int(), 10))); err != nil {
-			return err
-		}
-	case reflect.Float32, reflect.Float64:
-		if _, err := encoder.out.Write([]byte(strconv.FormatFloat(value.Float(), 'f', -1, bit_size(value.Kind())))); err != nil {
-			return err
-		}
-	case reflect.Bool:
-		if _, err := encoder.out.Write([]byte(strconv.FormatBool(value.Bool()))); err != nil {
-			return err
-		}
-	case reflect.String:
-		if err := encoder.encode_string(value.String()); err != nil {
-			return err
-		}
-	case reflect.Slice, reflect.Array:
-		encoder.out.Write([]byte("{\n"))
-		for x := 0; x < value.Len(); x++ {
-			if err := encoder.encode_value(depth+1, value.Index(x)); err != nil {
-				return err
-			}
-
-			if !is_bracketed_value(value.Index(x)) {
-				encoder.out.Write([]byte("\n"))
-			}
-		}
-		for x := 0; x < depth; x++ {
-			if _, err := encoder.out.Write([]byte(encoder.Indent)); err != nil {
-				return err
-			}
-		}
-		encoder.out.Write([]byte("}\n"))
-	case reflect.Struct:
-		encoder.out.Write([]byte("{\n"))
-
-		for x := 0; x < value.NumField(); x++ {
-			field := value.Field(x)
-
-			if field.IsZero() {
-				continue
-			}
-
-			encoder.writeIndentation(depth + 1)
-			encoder.out.Write([]byte(value.Type().Field(x).Name))
-
-			if is_bracketed_value(field) {
-				encoder.out.Write([]byte("\n"))
-				if err := encoder.encode_value(depth+1, field); err != nil {
-					return err
-				}
-			} else {
-				encoder.out.Write([]byte(" "))
-				if err := encoder.encode_value(0, field); err != nil {
-					return err
-				}
-				encoder.out.Write([]byte("\n"))
-			}
-		}
-
-		encoder.writeIndentation(depth)
-
-		encoder.out.Write([]byte("}\n"))
-	case reflect.Map:
-		encoder.out.Write([]byte("{\n"))
-		map_keys := value.MapKeys()
-		sort_values(map_keys)
-
-		for _, key := range map_keys {
-			if err := encoder.encode_value(depth+1, key); err != nil {
-				return err
-			}
-
-			field := value.MapIndex(key)
-
-			if is_bracketed_value(field) {
-				encoder.out.Write([]byte("\n"))
-				if err := encoder.encode_value(depth+1, field); err != nil {
-					return err
-				}
-			} else {
-				encoder.out.Write([]byte(" "))
-				if err := encoder.encode_value(0, field); err != nil {
-					return err
-				}
-				encoder.out.Write([]byte("\n"))
-			}
-		}
-		encoder.writeIndentation(depth)
-		encoder.out.Write([]byte("}\n"))
-	default:
-		return fmt.Errorf("unknown kind %s", value.Kind())
-	}
-
-	return nil
-}
-
-type value_sorter []reflect.Value
-
-func (vs value_sorter) Len() int {
-	return len(vs)
-}
-
-func (vs value_sorter) Less(i, j int) bool {
-	switch vs[0].Kind() {
-	case reflect.String:
-		return vs[i].String() < vs[j].String()
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return vs[i].Uint() < vs[j].Uint()
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return vs[i].Int() < vs[j].Int()
-	case reflect.Float32, reflect.Float64:
-		return vs[i].Float() < vs[j].Float()
-	default:
-		panic("unknown kind " + vs[0].Kind().String())
-	}
-}
-
-func (vs value_sorter) Swap(i, j int) {
-	_i := vs[i]
-	_j := vs[j]
-	vs[i] = _j
-	vs[j] = _i
-}
-
-func sort_values(values []reflect.Value) {
-	if len(values) == 0 {
-		return
-	}
-
-	sorter := value_sorter(values)
-	sort.Sort(sorter)
-}
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An Encoder writes text values to an output stream.
+//
+// Output is buffered: call Flush after the last value to write everything out.
+// Once writing to the output stream fails, Encode and Flush return the same error from then on.
+type Encoder struct {
+	// The row type of the table being written, or nil outside of a table
+	row_type reflect.Type
+	// The number of columns in a table begun by BeginGenericTable
+	table_columns int
+	// The output stream
+	writer io.Writer
+	// Output that has not been written to the output stream yet
+	out bytes.Buffer
+	// The first error returned by the output stream
+	err error
+	// What each indent should be. Preferably "\t" or "  "
+	Indent string
+
+	// Tabular encoding
+	Tabular bool
+	// If true, table headers give the type of each column, which Decoders check against the row type
+	TypedHeader bool
+}
+
+// The amount of buffered output that causes Encode to write to the output stream
+const flush_size = 32 * 1024
+
+func NewEncoder(out io.Writer) *Encoder {
+	return &Encoder{
+		writer: out,
+		Indent: "\t",
+	}
+}
+
+func (encoder *Encoder) writeIndentation(depth int) {
+	for x := 0; x < depth; x++ {
+		if _, err := encoder.out.Write([]byte(encoder.Indent)); err != nil {
+			return
+		}
+	}
+}
+
+func (encoder *Encoder) write_table_header(t reflect.Type) (err error) {
+	var fields *struct_fields
+	if fields, err = get_struct_fields(t); err != nil {
+		return
+	}
+
+	encoder.out.Write([]byte("[ "))
+	for i := range fields.list {
+		if err = encoder.encode_header_column(fields.list[i].name, t.FieldByIndex(fields.list[i].index).Type, encoder.TypedHeader); err != nil {
+			return
+		}
+		encoder.out.Write([]byte(" "))
+	}
+	_, err = encoder.out.Write([]byte("]\n"))
+	return
+}
+
+// Encode writes value to the output stream. If value cannot be encoded, nothing is written.
+func (encoder *Encoder) Encode(value any) (err error) {
+	if encoder.err != nil {
+		return encoder.err
+	}
+
+	mark := encoder.out.Len()
+	if err = encoder.encode(value); err != nil {
+		// Leave out the part of the value that was encoded
+		encoder.out.Truncate(mark)
+		return
+	}
+
+	if encoder.out.Len() >= flush_size {
+		err = encoder.Flush()
+	}
+	return
+}
+
+// BeginTable writes the header of a table whose rows are of type row_type, which must be a struct
+// or a pointer to one. Until EndTable is called, Encode writes each value as a row of the table,
+// and values of any other type are rejected.
+//
+// Setting Tabular begins a table with the first value encoded, so BeginTable and EndTable are
+// only needed to write several tables to one stream.
+func (encoder *Encoder) BeginTable(row_type reflect.Type) (err error) {
+	if encoder.err != nil {
+		return encoder.err
+	}
+
+	if encoder.row_type != nil {
+		return fmt.Errorf("text: cannot begin a table before the table of %s has ended", encoder.row_type)
+	}
+
+	if row_type.Kind() == reflect.Pointer {
+		row_type = row_type.Elem()
+	}
+	return encoder.begin_table(row_type)
+}
+
+// BeginGenericTable writes the header of a table with the given columns. Until EndTable is called,
+// Encode writes each []any as a row of the table, holding a generic value for each column,
+// or an empty []any as a zero row.
+func (encoder *Encoder) BeginGenericTable(columns []string) (err error) {
+	if encoder.err != nil {
+		return encoder.err
+	}
+
+	if encoder.row_type != nil {
+		return fmt.Errorf("text: cannot begin a table before the table of %s has ended", encoder.row_type)
+	}
+
+	if err = encoder.write_generic_header(columns); err != nil {
+		return
+	}
+	encoder.row_type = generic_row_type
+	encoder.table_columns = len(columns)
+	return
+}
+
+func (encoder *Encoder) begin_table(row_type reflect.Type) (err error) {
+	if row_type.Kind() != reflect.Struct {
+		return fmt.Errorf("to use tabular encoding, a row must be a struct")
+	}
+
+	if err = encoder.write_table_header(row_type); err != nil {
+		return
+	}
+	encoder.row_type = row_type
+	return
+}
+
+// EndTable ends the table begun by BeginTable, or by the first value encoded with Tabular set.
+// The next table then begins with its own header.
+func (encoder *Encoder) EndTable() (err error) {
+	if encoder.row_type == nil {
+		return fmt.Errorf("text: EndTable called outside of a table")
+	}
+
+	encoder.row_type = nil
+	return encoder.err
+}
+
+// Flush writes any buffered output to the output stream.
+func (encoder *Encoder) Flush() (err error) {
+	if encoder.err != nil {
+		return encoder.err
+	}
+
+	if _, err = encoder.out.WriteTo(encoder.writer); err != nil {
+		encoder.err = err
+	}
+	return
+}
+
+func (encoder *Encoder) encode(value any) (err error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if encoder.row_type != nil {
+		if v.Type() != encoder.row_type {
+			return fmt.Errorf("text: cannot encode %s as a row in a table of %s", v.Type(), encoder.row_type)
+		}
+		if encoder.row_type == generic_row_type {
+			return encoder.encode_generic_row(v.Interface().([]any), encoder.table_columns)
+		}
+		return encoder.encode_row(v)
+	}
+
+	if v.Type() == table_type {
+		table := v.Interface().(Table)
+		return encoder.encode_table(&table)
+	}
+
+	if encoder.Tabular {
+		// The first row begins a table
+		if err = encoder.begin_table(v.Type()); err != nil {
+			return
+		}
+		if err = encoder.encode_row(v); err != nil {
+			encoder.row_type = nil
+		}
+		return
+	}
+
+	return encoder.encode_value(0, v)
+}
+
+var word_escaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\"", "\\\"",
+	"\n", "\\n",
+	"\t", "\\t",
+	"\r", "\\r",
+)
+
+// Reports whether a string can be written as a bare word
+func is_bare_word(str string) bool {
+	// an empty word where a value should be can be confusing and/or perilous.
+	// The bare word nil is reserved for nil pointers, and a leading slash would begin a comment.
+	return str != "" && str != nil_word && str[0] != '/' && !strings.ContainsAny(str, " \n\t\r'\\\"{}[]")
+}
+
+// QuoteWord returns str as a quoted word, with escape sequences where necessary.
+func QuoteWord(str string) string {
+	return "\"" + word_escaper.Replace(str) + "\""
+}
+
+// FormatWord returns str as it would be written by an Encoder: as a bare word
+// if possible, otherwise as a quoted word.
+func FormatWord(str string) string {
+	if is_bare_word(str) {
+		return str
+	}
+	return QuoteWord(str)
+}
+
+func encode_string(out io.Writer, str string) error {
+	_, err := io.WriteString(out, FormatWord(str))
+	return err
+}
+
+func (encoder *Encoder) encode_string(str string) error {
+	return encode_string(&encoder.out, str)
+}
+
+// Writes a string as a quoted word, even if it could be written bare
+func (encoder *Encoder) encode_quoted(str string) error {
+	_, err := io.WriteString(&encoder.out, QuoteWord(str))
+	return err
+}
+
+func is_bracketed_value(field reflect.Value) bool {
+	if field.Kind() == reflect.Pointer {
+		// nil pointers are written as a word
+		if field.IsNil() {
+			return false
+		}
+		return is_bracketed_value(field.Elem())
+	}
+	if field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return false
+		}
+		if !is_typed_interface(field) {
+			return is_bracketed_value(field.Elem())
+		}
+		// Like bracketed values, typed interfaces end their own line
+		return true
+	}
+	if field.Type() == raw_value_type {
+		return RawValue(field.Bytes()).is_block()
+	}
+	if can_marshal_block(field) {
+		return true
+	}
+	return !can_encode_word(field) && (field.Kind() == reflect.Struct || field.Kind() == reflect.Array || field.Kind() == reflect.Slice || field.Kind() == reflect.Map)
+}
+
+func (encoder *Encoder) encode_word(value reflect.Value) (err error) {
+	var str string
+	str, err = word_string(value)
+	if err != nil {
+		return
+	}
+
+	err = encoder.encode_string(str)
+	return
+}
+
+// Writes the word that stands for a nil pointer
+func (encoder *Encoder) encode_nil() (err error) {
+	_, err = encoder.out.Write([]byte(nil_word))
+	return
+}
+
+// Encodes the value that follows a key at depth. Bracketed values begin on the next line.
+func (encoder *Encoder) encode_keyed_value(depth int, value reflect.Value) error {
+	if value.Kind() == reflect.Interface && !value.IsNil() && is_typed_interface(value) {
+		// The type name goes on the same line as the key
+		encoder.out.Write([]byte(" "))
+		if err := encoder.encode_type_name(value); err != nil {
+			return err
+		}
+		return encoder.encode_keyed_value(depth, value.Elem())
+	}
+
+	if is_bracketed_value(value) {
+		encoder.out.Write([]byte("\n"))
+		return encoder.encode_value(depth, value)
+	}
+
+	encoder.out.Write([]byte(" "))
+	if err := encoder.encode_value(0, value); err != nil {
+		return err
+	}
+	encoder.out.Write([]byte("\n"))
+	return nil
+}
+
+// Encodes an element of an unkeyed block at depth
+func (encoder *Encoder) encode_element(depth int, value reflect.Value) error {
+	if err := encoder.encode_value(depth, value); err != nil {
+		return err
+	}
+
+	if !is_bracketed_value(value) {
+		encoder.out.Write([]byte("\n"))
+	}
+	return nil
+}
+
+func (encoder *Encoder) encode_value(depth int, value reflect.Value) error {
+	if value.Kind() == reflect.Interface && !value.IsNil() && !is_typed_interface(value) {
+		if is_type_name_string(value.Elem()) {
+			encoder.writeIndentation(depth)
+			return encoder.encode_quoted(value.Elem().String())
+		}
+		return encoder.encode_value(depth, value.Elem())
+	}
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			encoder.writeIndentation(depth)
+			return encoder.encode_nil()
+		}
+		return encoder.encode_value(depth, value.Elem())
+	}
+
+	encoder.writeIndentation(depth)
+
+	if value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return encoder.encode_nil()
+		}
+		if err := encoder.encode_type_name(value); err != nil {
+			return err
+		}
+		return encoder.encode_keyed_value(depth, value.Elem())
+	}
+
+	if value.Type() == raw_value_type {
+		if err := encoder.encode_raw(depth, value.Bytes()); err != nil {
+			return err
+		}
+		if RawValue(value.Bytes()).is_block() {
+			encoder.out.Write([]byte("\n"))
+		}
+		return nil
+	}
+
+	if can_marshal_block(value) {
+		return encoder.encode_block(depth, value)
+	}
+
+	if can_encode_word(value) {
+		return encoder.encode_word(value)
+	}
+
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if _, err := encoder.out.Write([]byte(strconv.FormatUint(value.Uint(), 10))); err != nil {
+			return err
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, err := encoder.out.Write([]byte(strconv.FormatInt(value.Int(), 10))); err != nil {
+			return err
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, err := encoder.out.Write([]byte(strconv.FormatFloat(value.Float(), 'f', -1, bit_size(value.Kind())))); err != nil {
+			return err
+		}
+	case reflect.Bool:
+		if _, err := encoder.out.Write([]byte(strconv.FormatBool(value.Bool()))); err != nil {
+			return err
+		}
+	case reflect.String:
+		if err := encoder.encode_string(value.String()); err != nil {
+			return err
+		}
+	case reflect.Slice, reflect.Array:
+		encoder.out.Write([]byte("{\n"))
+		for x := 0; x < value.Len(); x++ {
+			if x == 0 && is_keyed_list(value) {
+				// Quoting the first word keeps the list from being decoded as a keyed block
+				encoder.writeIndentation(depth + 1)
+				if err := encoder.encode_quoted(value.Index(x).Elem().String()); err != nil {
+					return err
+				}
+				encoder.out.Write([]byte("\n"))
+				continue
+			}
+			if err := encoder.encode_element(depth+1, value.Index(x)); err != nil {
+				return err
+			}
+		}
+		for x := 0; x < depth; x++ {
+			if _, err := encoder.out.Write([]byte(encoder.Indent)); err != nil {
+				return err
+			}
+		}
+		encoder.out.Write([]byte("}\n"))
+	case reflect.Struct:
+		encoder.out.Write([]byte("{\n"))
+
+		fields, err := get_struct_fields(value.Type())
+		if err != nil {
+			return err
+		}
+
+		for x := range fields.list {
+			field, ok := field_value(value, &fields.list[x])
+
+			if !ok || is_omitted(&fields.list[x], field) {
+				continue
+			}
+
+			encoder.writeIndentation(depth + 1)
+			if err := encoder.encode_string(fields.list[x].name); err != nil {
+				return err
+			}
+
+			if err := encoder.encode_keyed_value(depth+1, field); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.encode_unknown_fields(depth+1, value, fields); err != nil {
+			return err
+		}
+
+		encoder.writeIndentation(depth)
+
+		encoder.out.Write([]byte("}\n"))
+	case reflect.Map:
+		encoder.out.Write([]byte("{\n"))
+		map_keys := value.MapKeys()
+		sort_values(map_keys)
+
+		for _, key := range map_keys {
+			if err := encoder.encode_value(depth+1, key); err != nil {
+				return err
+			}
+
+			if err := encoder.encode_keyed_value(depth+1, value.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+		encoder.writeIndentation(depth)
+		encoder.out.Write([]byte("}\n"))
+	default:
+		return fmt.Errorf("unknown kind %s", value.Kind())
+	}
+
+	return nil
+}
+
+type value_sorter []reflect.Value
+
+func (vs value_sorter) Len() int {
+	return len(vs)
+}
+
+func (vs value_sorter) Less(i, j int) bool {
+	switch vs[0].Kind() {
+	case reflect.String:
+		return vs[i].String() < vs[j].String()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return vs[i].Uint() < vs[j].Uint()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return vs[i].Int() < vs[j].Int()
+	case reflect.Float32, reflect.Float64:
+		return vs[i].Float() < vs[j].Float()
+	default:
+		panic("unknown kind " + vs[0].Kind().String())
+	}
+}
+
+func (vs value_sorter) Swap(i, j int) {
+	_i := vs[i]
+	_j := vs[j]
+	vs[i] = _j
+	vs[j] = _i
+}
+
+// Sorts values by the words they encode to
+type word_sorter struct {
+	values []reflect.Value
+	words  []string
+}
+
+func (ws *word_sorter) Len() int {
+	return len(ws.values)
+}
+
+func (ws *word_sorter) Less(i, j int) bool {
+	return ws.words[i] < ws.words[j]
+}
+
+func (ws *word_sorter) Swap(i, j int) {
+	ws.values[i], ws.values[j] = ws.values[j], ws.values[i]
+	ws.words[i], ws.words[j] = ws.words[j], ws.words[i]
+}
+
+func sort_values(values []reflect.Value) {
+	if len(values) == 0 {
+		return
+	}
+
+	if can_encode_word(values[0]) {
+		sorter := &word_sorter{
+			values: values,
+			words:  make([]string, len(values)),
+		}
+		for i, value := range values {
+			// Errors are reported when the value is encoded
+			sorter.words[i], _ = word_string(value)
+		}
+		sort.Sort(sorter)
+		return
+	}
+
+	sorter := value_sorter(values)
+	sort.Sort(sorter)
+}
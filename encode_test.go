@@ -2,6 +2,9 @@ package text_test
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"reflect"
 	"testing"
 
 	"github.com/Gophercraft/text"
@@ -35,6 +38,39 @@ var cases1 = []test_case1{
 
 		EncodedTable: `[ ID Key Strings ]
 { 1 ABCDEFGHIJKLMNOP { 00 01 02 03 } }
+`,
+	},
+	{
+		Record1: []record1{
+			{
+				ID:  1,
+				Key: "A",
+			},
+			{
+				ID:      2,
+				Key:     "B",
+				Strings: []string{"x"},
+			},
+		},
+
+		EncodedTable: `[ ID Key Strings ]
+{ 1 A {} }
+{ 2 B { x } }
+`,
+	},
+	{
+		// A zero row ends its own line
+		Record1: []record1{
+			{},
+			{
+				ID:  2,
+				Key: "B",
+			},
+		},
+
+		EncodedTable: `[ ID Key Strings ]
+{}
+{ 2 B {} }
 `,
 	},
 }
@@ -51,6 +87,9 @@ func TestEncodeTable(t *testing.T) {
 			if err := encoder.Encode(&record); err != nil {
 				t.Fatal(err)
 			}
+			if err := encoder.Flush(); err != nil {
+				t.Fatal(err)
+			}
 		}
 
 		str := buf.String()
@@ -61,3 +100,183 @@ func TestEncodeTable(t *testing.T) {
 	}
 
 }
+
+func TestFormatWord(t *testing.T) {
+	words := map[string]string{
+		"plain":       `plain`,
+		"two words":   `"two words"`,
+		"":            `""`,
+		"nil":         `"nil"`,
+		"/path":       `"/path"`,
+		"line\nbreak": `"line\nbreak"`,
+		`back\slash`:  `"back\\slash"`,
+		`a "quote"`:   `"a \"quote\""`,
+	}
+
+	for word, expected := range words {
+		formatted := text.FormatWord(word)
+		if formatted != expected {
+			t.Fatal(formatted, "should have been equal to", expected)
+		}
+
+		var decoded string
+		if err := text.Unmarshal([]byte(formatted), &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded != word {
+			t.Fatal(decoded, "should have been equal to", word)
+		}
+	}
+}
+
+// Accepts a limited number of bytes, then fails
+type failing_writer struct {
+	limit int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (writer *failing_writer) Write(p []byte) (n int, err error) {
+	if len(p) > writer.limit {
+		n = writer.limit
+		writer.limit = 0
+		return n, errDiskFull
+	}
+	writer.limit -= len(p)
+	return len(p), nil
+}
+
+func TestEncodeWriteError(t *testing.T) {
+	encoder := text.NewEncoder(&failing_writer{limit: 8})
+	encoder.Tabular = true
+	encoder.Indent = " "
+
+	if err := encoder.Encode(&cases1[0].Record1[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); !errors.Is(err, errDiskFull) {
+		t.Fatal("expected a write error, got", err)
+	}
+
+	// The first error is returned from then on
+	if err := encoder.Encode(&cases1[0].Record1[0]); !errors.Is(err, errDiskFull) {
+		t.Fatal("expected a write error, got", err)
+	}
+	if err := encoder.Flush(); !errors.Is(err, errDiskFull) {
+		t.Fatal("expected a write error, got", err)
+	}
+}
+
+func TestEncodeErrorWritesNothing(t *testing.T) {
+	var out bytes.Buffer
+	encoder := text.NewEncoder(&out)
+
+	if err := encoder.Encode(&record1{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := out.Len()
+
+	// The invalid raw value is only found after the ID has been encoded
+	value := raw_plugin{
+		Name:   "chat",
+		Config: text.RawValue("{ unbalanced"),
+	}
+	if err := encoder.Encode(&value); err == nil {
+		t.Fatal("expected an error for an invalid raw value")
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Len() != expected {
+		t.Fatalf("partial value was written\n%s", out.String())
+	}
+}
+
+type record2 struct {
+	Name  string
+	Level uint8
+}
+
+func TestEncodeMultipleTables(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+
+	if err := encoder.BeginTable(reflect.TypeFor[*record1]()); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range cases1[1].Record1 {
+		if err := encoder.Encode(&record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Encode(&record2{}); err == nil {
+		t.Fatal("expected an error for a row of the wrong type")
+	}
+	if err := encoder.EndTable(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := encoder.BeginTable(reflect.TypeFor[record2]()); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Encode(&record2{"Thrall", 60}); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.EndTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cases1[1].EncodedTable + `[ Name Level ]
+{ Thrall 60 }
+`
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	decoder := text.NewDecoder(&buf)
+
+	if err := decoder.NextTable(); err != nil {
+		t.Fatal(err)
+	}
+	var records []record1
+	for decoder.More() {
+		var record record1
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if !reflect.DeepEqual(records, cases1[1].Record1) {
+		t.Fatal("got back incorrect records", records)
+	}
+
+	if err := decoder.NextTable(); err != nil {
+		t.Fatal(err)
+	}
+	if columns := decoder.Columns(); !reflect.DeepEqual(columns, []string{"Name", "Level"}) {
+		t.Fatal("wrong columns", columns)
+	}
+	var record record2
+	if err := decoder.Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if record != (record2{"Thrall", 60}) {
+		t.Fatal("got back incorrect record", record)
+	}
+
+	if decoder.More() {
+		t.Fatal("expected the end of the input")
+	}
+	if err := decoder.NextTable(); !errors.Is(err, io.EOF) {
+		t.Fatal("expected the end of the input, got", err)
+	}
+}
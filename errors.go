@@ -0,0 +1,226 @@
+package text
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A position in the input stream
+type position struct {
+	// Byte offset from the start of the input
+	offset int64
+	// Line and column, counting from 1. Columns count runes, not bytes.
+	line, column int
+}
+
+// A SyntaxError describes text that does not follow the grammar of the format,
+// such as a stray comment, an unterminated quote, or a token where a different one was expected.
+type SyntaxError struct {
+	Msg string
+	// Where the offending token or character begins
+	Line   int
+	Column int
+	Offset int64
+	// The underlying cause, if any (for example io.ErrUnexpectedEOF)
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("text: syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// A DecodeError describes a failure to decode a value into a Go value.
+// Path locates the value being decoded, for example "Spells[12].Effects.Radius".
+// Err is the underlying cause, which may be a *SyntaxError.
+type DecodeError struct {
+	Path   string
+	Line   int
+	Column int
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	var s strings.Builder
+	s.WriteString("text: error decoding ")
+	if e.Path != "" {
+		s.WriteString(e.Path)
+		s.WriteString(" ")
+	}
+	fmt.Fprintf(&s, "at line %d, column %d: ", e.Line, e.Column)
+	if syntax_error, ok := e.Err.(*SyntaxError); ok {
+		s.WriteString(syntax_error.Msg)
+	} else {
+		s.WriteString(e.Err.Error())
+	}
+	return s.String()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// An UnknownField is a key or column that has no corresponding struct field.
+type UnknownField struct {
+	// The path to the key, for example "Spells[12].Cooldown"
+	Path   string
+	Line   int
+	Column int
+	Offset int64
+}
+
+// An UnknownFieldError lists every unknown key and column in a value.
+// It is returned after the rest of the value has been decoded, so the decoded value
+// is complete and the Decoder is ready to decode the next value.
+type UnknownFieldError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldError) Error() string {
+	var s strings.Builder
+	s.WriteString("text: unknown field")
+	if len(e.Fields) > 1 {
+		s.WriteString("s")
+	}
+	for i, field := range e.Fields {
+		if i > 0 {
+			s.WriteString(",")
+		}
+		fmt.Fprintf(&s, " %s (line %d, column %d)", field.Path, field.Line, field.Column)
+	}
+	return s.String()
+}
+
+// Creates a SyntaxError located at pos
+func syntax_error(pos position, format string, args ...any) *SyntaxError {
+	return &SyntaxError{
+		Msg:    fmt.Sprintf(format, args...),
+		Line:   pos.line,
+		Column: pos.column,
+		Offset: pos.offset,
+	}
+}
+
+// An element of the path to the value being decoded
+type path_element struct {
+	// A field name, column name or map key
+	name string
+	// Set if name is a map key
+	key bool
+	// Used for slice and array elements when name is empty
+	index int
+}
+
+func (decoder *Decoder) push_field(name string) {
+	decoder.path = append(decoder.path, path_element{name: name})
+}
+
+func (decoder *Decoder) push_key(key string) {
+	decoder.path = append(decoder.path, path_element{name: key, key: true})
+}
+
+// Returns the name of a decoded map key for use in the path. If the key is not a string, the name is
+// the text of the key's word, copied before the key was decoded, or the key's value if it is a block.
+func key_name(key reflect.Value, word string) string {
+	switch {
+	case key.Kind() == reflect.String:
+		return key.String()
+	case word != "":
+		return word
+	default:
+		return fmt.Sprint(key.Interface())
+	}
+}
+
+// Returns a copy of the text of a map key's first token, if the key is not a string.
+// The token's text refers to the Decoder's buffer, which decoding the key may overwrite.
+func key_word(key_type reflect.Type, key_token token) string {
+	if key_type.Kind() == reflect.String {
+		return ""
+	}
+	return string(key_token.Data)
+}
+
+func (decoder *Decoder) push_index(index int) {
+	decoder.path = append(decoder.path, path_element{index: index})
+}
+
+func (decoder *Decoder) pop_path() {
+	decoder.path = decoder.path[:len(decoder.path)-1]
+}
+
+// Formats the path to the value currently being decoded
+func (decoder *Decoder) path_string() string {
+	var s strings.Builder
+	for i, element := range decoder.path {
+		if element.key {
+			s.WriteString("[")
+			s.WriteString(strconv.Quote(element.name))
+			s.WriteString("]")
+			continue
+		}
+		if element.name == "" {
+			s.WriteString("[")
+			s.WriteString(strconv.Itoa(element.index))
+			s.WriteString("]")
+			continue
+		}
+		if i > 0 {
+			s.WriteString(".")
+		}
+		s.WriteString(element.name)
+	}
+	return s.String()
+}
+
+// Records a key or column with no corresponding field, found at pos
+func (decoder *Decoder) unknown_field(name string, pos position) {
+	path := decoder.path_string()
+	if path != "" {
+		path += "."
+	}
+	decoder.unknown_fields = append(decoder.unknown_fields, UnknownField{
+		Path:   path + name,
+		Line:   pos.line,
+		Column: pos.column,
+		Offset: pos.offset,
+	})
+}
+
+// Attaches the current path and position to an error, unless it already has them.
+// The end of the input between values is returned as io.EOF itself.
+func (decoder *Decoder) decode_error(err error) error {
+	if err == io.EOF {
+		return err
+	}
+
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *DecodeError, *UnknownFieldError:
+		return e
+	case *SyntaxError:
+		return &DecodeError{
+			Path:   decoder.path_string(),
+			Line:   e.Line,
+			Column: e.Column,
+			Offset: e.Offset,
+			Err:    err,
+		}
+	}
+
+	return &DecodeError{
+		Path:   decoder.path_string(),
+		Line:   decoder.last.line,
+		Column: decoder.last.column,
+		Offset: decoder.last.offset,
+		Err:    err,
+	}
+}
@@ -0,0 +1,103 @@
+package text_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type error_effect struct {
+	Radius uint8
+}
+
+type error_spell struct {
+	Name    string
+	Effects []error_effect
+}
+
+type error_document struct {
+	Spells map[string]error_spell
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	input := `{
+	/* a comment */ Spells
+	{
+		"Fire Ball" {
+			Name "Fire Ball" // trailing comment
+			Effects { { Radius 1 } { Radius 300 } }
+		}
+	}
+}`
+
+	var document error_document
+	err := text.Unmarshal([]byte(input), &document)
+
+	var decode_error *text.DecodeError
+	if !errors.As(err, &decode_error) {
+		t.Fatal("expected a *DecodeError, got", err)
+	}
+
+	if decode_error.Path != `Spells["Fire Ball"].Effects[1].Radius` {
+		t.Fatal("wrong path", decode_error.Path)
+	}
+
+	if decode_error.Line != 6 || decode_error.Column != 36 {
+		t.Fatal("wrong position", decode_error.Line, decode_error.Column)
+	}
+
+	if decode_error.Offset != int64(strings.Index(input, "300")) {
+		t.Fatal("wrong offset", decode_error.Offset)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	type case_ struct {
+		input        string
+		line, column int
+	}
+
+	cases := []case_{
+		{"{ Name \"a\" / }", 1, 12},
+		{"{\n\tName \"quoted \\q\"\n}", 2, 16},
+		{"{ \"Name\" x \"Effects\" x }", 1, 22},
+		{"{ Name /* unterminated", 1, 23},
+	}
+
+	for _, c := range cases {
+		var spell error_spell
+		err := text.Unmarshal([]byte(c.input), &spell)
+
+		var syntax_error *text.SyntaxError
+		if !errors.As(err, &syntax_error) {
+			t.Fatal("expected a *SyntaxError, got", err)
+		}
+
+		if syntax_error.Line != c.line || syntax_error.Column != c.column {
+			t.Fatal(c.input, "wrong position", syntax_error.Line, syntax_error.Column, err)
+		}
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	var spell error_spell
+	err := text.Unmarshal([]byte("{ Name a Effects { "), &spell)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		t.Fatal("expected an unexpected EOF, got", err)
+	}
+}
+
+func TestEOF(t *testing.T) {
+	// The end of the input between values is io.EOF itself
+	decoder := text.NewDecoder(strings.NewReader("{ Name a }\n"))
+	var spell error_spell
+	if err := decoder.Decode(&spell); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoder.Decode(&spell); err != io.EOF {
+		t.Fatal("expected io.EOF, got", err)
+	}
+}
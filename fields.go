@@ -0,0 +1,357 @@
+package text
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// A field describes how a Go struct field is named and located in text.
+// Fields are controlled by the `text` struct tag:
+//
+//	Field int `text:"name"`           // encoded under the key "name"
+//	Field int `text:"name,omitempty"` // also omit empty slices, maps and strings in keyed blocks
+//	Field T   `text:",inline"`        // the fields of struct T appear directly in the parent
+//	Field int `text:"-"`              // never encoded or decoded
+//
+// Tables written by older versions of a program can be read with these options:
+//
+//	Field int `text:"name,alias=old"` // also decoded from the key or column "old"
+//	Field int `text:",default=5"`     // decoded from the word 5 if a table has no column for the field
+//	Field int `text:",required"`      // a table must have a column for the field
+//
+// The alias option may be repeated. A field's own name takes precedence over the aliases of other fields.
+// The default option takes the rest of the tag, commas included, so it must be the last option.
+// Any other option is reported as an error when the struct is encoded or decoded.
+//
+// A field of type map[string]RawValue with the "unknown" option, such as
+//
+//	Extra map[string]text.RawValue `text:",unknown"`
+//
+// receives the keys and columns that have no corresponding field, with their values as written.
+//
+// The fields of embedded structs are promoted as if they were inlined, following the
+// same rules as encoding/json: a shallower field hides deeper ones with the same name,
+// and among fields at the same depth a tagged field wins. If the conflict cannot be
+// resolved this way, none of the fields are used.
+type field struct {
+	// The key (or column name) used in text
+	name string
+	// The index sequence for reflect.Value.FieldByIndex
+	index     []int
+	omitempty bool
+	// Set if the name came from a struct tag
+	tagged bool
+	// Older names that the field is also decoded from
+	aliases []string
+	// The text of the value used when a table has no column for the field
+	default_value string
+	has_default   bool
+	// Set if a table must have a column for the field
+	required bool
+}
+
+// The set of fields belonging to a struct type
+type struct_fields struct {
+	list    []field
+	by_name map[string]int
+	// The field that receives unknown keys, if any
+	unknown *field
+	// The first invalid struct tag
+	err error
+}
+
+// Split a struct tag into its name and options. The default option takes the rest of the tag.
+func parse_tag(tag string) (name string, options []string) {
+	name, rest, _ := strings.Cut(tag, ",")
+	for rest != "" {
+		if strings.HasPrefix(rest, "default=") {
+			return name, append(options, rest)
+		}
+
+		var option string
+		option, rest, _ = strings.Cut(rest, ",")
+		if option != "" {
+			options = append(options, option)
+		}
+	}
+	return
+}
+
+// Returns an error for the first option of a struct tag that is not known
+func check_options(t reflect.Type, struct_field reflect.StructField, options []string) error {
+	for _, option := range options {
+		switch key, _, _ := strings.Cut(option, "="); key {
+		case "omitempty", "inline", "unknown", "required":
+			if key == option {
+				continue
+			}
+		case "alias", "default":
+			if key != option {
+				continue
+			}
+		}
+		return fmt.Errorf("text: field %s of %s has an unknown tag option %q", struct_field.Name, t, option)
+	}
+	return nil
+}
+
+func has_option(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the values of the options written as option=value
+func option_values(options []string, option string) (values []string) {
+	for _, o := range options {
+		if value, ok := strings.CutPrefix(o, option+"="); ok {
+			values = append(values, value)
+		}
+	}
+	return
+}
+
+// A struct type whose fields are being promoted
+type promoted_struct struct {
+	t     reflect.Type
+	index []int
+}
+
+// Returns the list of fields that are represented in text for a struct type,
+// or an error if one of its struct tags is invalid
+func get_struct_fields(t reflect.Type) (fields *struct_fields, err error) {
+	fields = get_type_info(t).fields
+	return fields, fields.err
+}
+
+// Works out the list of fields that are represented in text for a struct type
+func build_struct_fields(t reflect.Type) *struct_fields {
+	var (
+		list    []field
+		unknown *field
+		tag_err error
+		// Structs to explore at the current and next depth
+		current []promoted_struct
+		next    = []promoted_struct{{t: t}}
+		// The number of times a struct type appears at the current and next depth
+		count      map[reflect.Type]int
+		next_count = map[reflect.Type]int{}
+		visited    = map[reflect.Type]bool{}
+	)
+
+	for len(next) > 0 {
+		current, next = next, nil
+		count, next_count = next_count, map[reflect.Type]int{}
+
+		for _, promoted := range current {
+			if visited[promoted.t] {
+				continue
+			}
+			visited[promoted.t] = true
+
+			for i := range promoted.t.NumField() {
+				struct_field := promoted.t.Field(i)
+
+				field_type := struct_field.Type
+				if field_type.Name() == "" && field_type.Kind() == reflect.Pointer {
+					field_type = field_type.Elem()
+				}
+
+				if struct_field.Anonymous {
+					// The exported fields of an unexported embedded struct are still promoted,
+					// unless it is embedded through a pointer that could not be allocated.
+					if !struct_field.IsExported() && (field_type.Kind() != reflect.Struct || struct_field.Type.Kind() == reflect.Pointer) {
+						continue
+					}
+				} else if !struct_field.IsExported() {
+					continue
+				}
+
+				tag := struct_field.Tag.Get("text")
+				if tag == "-" {
+					continue
+				}
+
+				name, options := parse_tag(tag)
+				if err := check_options(promoted.t, struct_field, options); err != nil && tag_err == nil {
+					tag_err = err
+				}
+
+				field_index := make([]int, len(promoted.index)+1)
+				copy(field_index, promoted.index)
+				field_index[len(promoted.index)] = i
+
+				if has_option(options, "unknown") && is_unknown_field_type(struct_field.Type) {
+					// The shallowest catch-all field is used
+					if unknown == nil {
+						unknown = &field{name: struct_field.Name, index: field_index}
+					}
+					continue
+				}
+
+				promote := (struct_field.Anonymous && name == "") || has_option(options, "inline")
+
+				if !promote || field_type.Kind() != reflect.Struct {
+					f := field{
+						name:      name,
+						index:     field_index,
+						omitempty: has_option(options, "omitempty"),
+						tagged:    name != "",
+						aliases:   option_values(options, "alias"),
+						required:  has_option(options, "required"),
+					}
+					if defaults := option_values(options, "default"); len(defaults) > 0 {
+						f.default_value = defaults[len(defaults)-1]
+						f.has_default = true
+					}
+					if f.name == "" {
+						f.name = struct_field.Name
+					}
+					list = append(list, f)
+					if count[promoted.t] > 1 {
+						// The struct was embedded more than once at this depth, so its fields conflict
+						// with each other. A duplicate ensures they are removed below.
+						list = append(list, f)
+					}
+					continue
+				}
+
+				next_count[field_type]++
+				if next_count[field_type] == 1 {
+					next = append(next, promoted_struct{t: field_type, index: field_index})
+				}
+			}
+		}
+	}
+
+	// Group fields by name, in order of dominance
+	slices.SortStableFunc(list, func(a, b field) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.index), len(b.index)); c != 0 {
+			return c
+		}
+		if a.tagged != b.tagged {
+			if a.tagged {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+
+	dominant := list[:0:0]
+	for i := 0; i < len(list); {
+		j := i + 1
+		for j < len(list) && list[j].name == list[i].name {
+			j++
+		}
+
+		group := list[i:j]
+		if len(group) == 1 || len(group[0].index) < len(group[1].index) || group[0].tagged != group[1].tagged {
+			dominant = append(dominant, group[0])
+		}
+		i = j
+	}
+
+	// Fields appear in the order they are declared
+	slices.SortFunc(dominant, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+
+	fields := &struct_fields{
+		list:    dominant,
+		by_name: make(map[string]int, len(dominant)),
+		unknown: unknown,
+		err:     tag_err,
+	}
+	for i := range dominant {
+		fields.by_name[dominant[i].name] = i
+	}
+	for i := range dominant {
+		for _, alias := range dominant[i].aliases {
+			if _, taken := fields.by_name[alias]; !taken {
+				fields.by_name[alias] = i
+			}
+		}
+	}
+	return fields
+}
+
+// Reports whether a type can hold unknown keys and their values
+func is_unknown_field_type(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem() == raw_value_type
+}
+
+// Looks up a field by its text name
+func (fields *struct_fields) lookup(name string) (f *field, ok bool) {
+	var i int
+	i, ok = fields.by_name[name]
+	if ok {
+		f = &fields.list[i]
+	}
+	return
+}
+
+// Returns the value of a field for encoding. If the field is promoted through a nil
+// pointer to an embedded struct, ok is false.
+func field_value(value reflect.Value, f *field) (field_value reflect.Value, ok bool) {
+	field_value = value
+	for i, x := range f.index {
+		if i > 0 && field_value.Kind() == reflect.Pointer {
+			if field_value.IsNil() {
+				return reflect.Value{}, false
+			}
+			field_value = field_value.Elem()
+		}
+		field_value = field_value.Field(x)
+	}
+	return field_value, true
+}
+
+// Returns the value of a field for encoding in a column. Fields promoted through a nil
+// pointer to an embedded struct are encoded as their zero value.
+func column_value(value reflect.Value, f *field) reflect.Value {
+	if v, ok := field_value(value, f); ok {
+		return v
+	}
+	return reflect.Zero(value.Type().FieldByIndex(f.index).Type)
+}
+
+// Returns the value of a field for decoding, allocating nil pointers to embedded structs along the way
+func field_value_alloc(value reflect.Value, f *field) reflect.Value {
+	field_value := value
+	for i, x := range f.index {
+		if i > 0 && field_value.Kind() == reflect.Pointer {
+			if field_value.IsNil() {
+				field_value.Set(reflect.New(field_value.Type().Elem()))
+			}
+			field_value = field_value.Elem()
+		}
+		field_value = field_value.Field(x)
+	}
+	return field_value
+}
+
+// Reports whether a field value should be left out of a keyed block
+func is_omitted(f *field, value reflect.Value) bool {
+	if value.IsZero() {
+		return true
+	}
+
+	if f.omitempty {
+		switch value.Kind() {
+		case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+			return value.Len() == 0
+		}
+	}
+
+	return false
+}
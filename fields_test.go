@@ -0,0 +1,322 @@
+package text_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type tag_position struct {
+	X int32
+	Y int32
+}
+
+type tag_record struct {
+	ID       uint32 `text:"Entry"`
+	Name     string
+	Internal string       `text:"-"`
+	Position tag_position `text:",inline"`
+	Aliases  []string     `text:",omitempty"`
+}
+
+func TestStructTags(t *testing.T) {
+	record := tag_record{
+		ID:       12,
+		Name:     "Hogger",
+		Internal: "not encoded",
+		Position: tag_position{X: -5, Y: 7},
+		Aliases:  []string{},
+	}
+
+	data, err := text.Marshal(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n\tEntry 12\n\tName Hogger\n\tX -5\n\tY 7\n}\n"
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+
+	var decoded tag_record
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	record.Internal = ""
+	record.Aliases = nil
+	if !reflect.DeepEqual(record, decoded) {
+		t.Fatal("got back incorrect record", decoded)
+	}
+
+	if err = text.Unmarshal([]byte("{ ID 12 }"), &decoded); err == nil {
+		t.Fatal("the Go field name should not be accepted in place of the tag name")
+	}
+}
+
+func TestStructTagsTable(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+	encoder.Tabular = true
+
+	record := tag_record{ID: 1, Name: "Test", Position: tag_position{X: 1, Y: 2}, Aliases: []string{"a"}}
+	if err := encoder.Encode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[ Entry Name X Y Aliases ]\n{ 1 Test 1 2 { a } }\n"
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	var decoded tag_record
+	if err := text.NewDecoder(strings.NewReader(expected)).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(record, decoded) {
+		t.Fatal("got back incorrect record", decoded)
+	}
+}
+
+type EntityBase struct {
+	ID   uint64
+	Name string
+}
+
+type entityFlags struct {
+	Flags uint32
+}
+
+type Placement struct {
+	Map  uint32
+	Name string // conflicts with EntityBase.Name at the same depth
+}
+
+type promoted_creature struct {
+	*EntityBase
+	entityFlags
+	Level uint8
+	// Shallower than EntityBase.ID, so it hides it
+	ID string `text:"GUID"`
+}
+
+type conflicting_creature struct {
+	EntityBase
+	Placement
+	Level uint8
+}
+
+func TestEmbeddedPromotion(t *testing.T) {
+	creature := promoted_creature{
+		EntityBase:  &EntityBase{ID: 1, Name: "Hogger"},
+		entityFlags: entityFlags{Flags: 2},
+		Level:       11,
+		ID:          "Creature-0-1",
+	}
+
+	data, err := text.Marshal(&creature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n\tID 1\n\tName Hogger\n\tFlags 2\n\tLevel 11\n\tGUID Creature-0-1\n}\n"
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+
+	var decoded promoted_creature
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(creature, decoded) {
+		t.Fatal("got back incorrect creature", decoded)
+	}
+
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+	encoder.Tabular = true
+	if err = encoder.Encode(&promoted_creature{Level: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "[ ID Name Flags Level GUID ]\n{ 0 \"\" 0 1 \"\" }\n"
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	if err = text.NewDecoder(strings.NewReader("[ Name Level ]\n{ Hogger 11 }")).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.EntityBase == nil || decoded.Name != "Hogger" || decoded.Level != 11 {
+		t.Fatal("got back incorrect creature", decoded)
+	}
+}
+
+func TestEmbeddedConflict(t *testing.T) {
+	creature := conflicting_creature{
+		EntityBase: EntityBase{ID: 1, Name: "a"},
+		Placement:  Placement{Map: 2, Name: "b"},
+		Level:      3,
+	}
+
+	data, err := text.Marshal(&creature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n\tID 1\n\tMap 2\n\tLevel 3\n}\n"
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+}
+
+type column_spell struct {
+	ID   uint32
+	Name string
+}
+
+type column_spell_renamed struct {
+	Name string `text:"ID"`
+	ID   string `text:"Name"`
+}
+
+func TestRowTypesShareHeader(t *testing.T) {
+	decoder := text.NewDecoder(strings.NewReader("[ ID Name ]\n{ 1 Fireball }\n{ 2 Frostbolt }\n{ 3 Blizzard }\n"))
+
+	var spell column_spell
+	if err := decoder.Decode(&spell); err != nil || spell.ID != 1 || spell.Name != "Fireball" {
+		t.Fatal("got back incorrect spell", spell, err)
+	}
+
+	// The columns are resolved again for a different row type
+	var renamed column_spell_renamed
+	if err := decoder.Decode(&renamed); err != nil || renamed.Name != "2" || renamed.ID != "Frostbolt" {
+		t.Fatal("got back incorrect spell", renamed, err)
+	}
+
+	if err := decoder.Decode(&spell); err != nil || spell.ID != 3 || spell.Name != "Blizzard" {
+		t.Fatal("got back incorrect spell", spell, err)
+	}
+}
+
+type evolving_spell struct {
+	ID       uint32  `text:",required"`
+	Name     string  `text:",alias=SpellName,alias=Title"`
+	Cooldown uint32  `text:",default=1500"`
+	Ranks    []uint8 `text:",default={ 1 2 }"`
+	School   string
+}
+
+func TestTableSchemaEvolution(t *testing.T) {
+	layouts := []string{
+		"[ ID Name Cooldown Ranks School ]\n{ 1 Fireball 1500 { 1 2 } Fire }\n{ 2 Frostbolt 1500 { 1 2 } Frost }\n",
+		// An older layout, with renamed, reordered and missing columns
+		"[ SpellName ID School ]\n{ Fireball 1 Fire }\n{ Frostbolt 2 Frost }\n",
+	}
+	expected := []evolving_spell{
+		{1, "Fireball", 1500, []uint8{1, 2}, "Fire"},
+		{2, "Frostbolt", 1500, []uint8{1, 2}, "Frost"},
+	}
+
+	for _, layout := range layouts {
+		spells, err := text.DecodeAll[evolving_spell](strings.NewReader(layout))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(spells, expected) {
+			t.Fatal("got back incorrect rows", spells)
+		}
+		// Default values are not shared between rows
+		if &spells[0].Ranks[0] == &spells[1].Ranks[0] {
+			t.Fatal("rows share a default value")
+		}
+	}
+
+	decoder := text.NewDecoder(strings.NewReader("[ Title ID Extra ]\n"))
+	if err := decoder.NextTable(); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := decoder.Mapping(reflect.TypeFor[evolving_spell]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected_mapping := &text.TableMapping{
+		Columns: []text.ColumnMapping{
+			{Column: "Title", Field: "Name", Alias: true},
+			{Column: "ID", Field: "ID"},
+			{Column: "Extra"},
+		},
+		Defaults: []string{"Cooldown", "Ranks"},
+		Missing:  []string{"School"},
+	}
+	if !reflect.DeepEqual(mapping, expected_mapping) {
+		t.Fatalf("wrong mapping %+v", mapping)
+	}
+
+	// Aliases also apply to keyed documents
+	var spell evolving_spell
+	if err = text.Unmarshal([]byte("{\n\tID 3\n\tSpellName Blizzard\n}"), &spell); err != nil {
+		t.Fatal(err)
+	}
+	if spell.ID != 3 || spell.Name != "Blizzard" {
+		t.Fatal("got back incorrect value", spell)
+	}
+
+	failures := map[string]string{
+		"[ Name ]\n{ Fireball }\n":                 "no column for required field ID",
+		"[ ID Name Title ]\n{ 1 Fireball Fire }\n": "columns Name and Title are both decoded into field Name",
+	}
+	for input, message := range failures {
+		var spell evolving_spell
+		err := text.Unmarshal([]byte(input), &spell)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("expected an error containing %q, got %v", message, err)
+		}
+	}
+}
+
+type comma_default struct {
+	ID       uint32
+	Greeting string   `text:",default=\"Hello, world\""`
+	Tags     []string `text:"Labels,omitempty,default={ \"a, b\" c }"`
+}
+
+type misspelled_tag struct {
+	ID   uint32
+	Name string `text:",omitempy"`
+}
+
+func TestTagOptions(t *testing.T) {
+	// A default value takes the rest of the tag, commas included
+	rows, err := text.DecodeAll[comma_default](strings.NewReader("[ ID ]\n{ 1 }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := comma_default{ID: 1, Greeting: "Hello, world", Tags: []string{"a, b", "c"}}
+	if !reflect.DeepEqual(rows[0], expected) {
+		t.Fatalf("wrong default values %+v", rows[0])
+	}
+
+	// Unknown options are reported instead of being ignored
+	if _, err = text.Marshal(&misspelled_tag{ID: 1}); err == nil || !strings.Contains(err.Error(), `unknown tag option "omitempy"`) {
+		t.Fatal("expected an error for an unknown option, got", err)
+	}
+	var misspelled misspelled_tag
+	if err = text.Unmarshal([]byte("{ ID 1 }"), &misspelled); err == nil || !strings.Contains(err.Error(), `unknown tag option "omitempy"`) {
+		t.Fatal("expected an error for an unknown option, got", err)
+	}
+}
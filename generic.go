@@ -0,0 +1,310 @@
+package text
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// A Table is a tabular document decoded without a Go type for its rows.
+//
+// Decoding into an empty interface (any) produces generic values: a keyed block becomes
+// a map[string]any, an unkeyed block becomes a []any, a word becomes a string, and the
+// bare word nil becomes nil. A tabular document decoded into an empty interface, or into
+// a Table, becomes a Table whose rows hold the values of each column in order.
+//
+// A block is keyed if it holds an even number of values, and every other value, beginning
+// with the first, is a distinct bare word that is not nil and not a number. Line breaks and
+// spacing make no difference, so { Name Thrall Level 60 } is keyed while { 1 2 3 4 } and
+// { Thrall 60 Thrall 70 } are not. An empty block and the blocks in table rows are always
+// unkeyed. A registered type name and the value after it are decoded as a value of that type.
+//
+// When a []any would look keyed, its first word is quoted so that it is decoded again as a list.
+// A map[string]any that is empty, or has keys that are numbers or need quoting, is decoded
+// again as a []any.
+//
+// Encoding a Table writes a tabular document. Generic values are encoded like any other value.
+type Table struct {
+	Columns []string
+	Rows    [][]any
+}
+
+var (
+	table_type       = reflect.TypeFor[Table]()
+	generic_row_type = reflect.TypeFor[[]any]()
+)
+
+// Reports whether a value can hold a tabular document
+func is_table_value(value reflect.Value) bool {
+	return value.Type() == table_type || (value.Kind() == reflect.Interface && value.NumMethod() == 0)
+}
+
+// A generic value, and whether it can be the key of a keyed block
+type generic_item struct {
+	value any
+	// The text of a word
+	word   string
+	is_key bool
+}
+
+// Decodes a generic value into an empty interface
+func (decoder *Decoder) decode_generic(value reflect.Value, column bool) (err error) {
+	var item generic_item
+	item, err = decoder.read_generic(column, true)
+	if err != nil {
+		return
+	}
+
+	if item.value == nil {
+		value.Set(reflect.Zero(value.Type()))
+		return
+	}
+
+	value.Set(reflect.ValueOf(item.value))
+	return
+}
+
+// Reads a generic value. If typed is true, a bare registered type name and the value after it
+// are decoded as one value of that type, as in decode_interface.
+func (decoder *Decoder) read_generic(column, typed bool) (item generic_item, err error) {
+	var t token
+	t, err = decoder.peek_token()
+	if err != nil {
+		return
+	}
+
+	if typed && is_type_name(t) {
+		var typed_value any
+		if err = decoder.decode_interface(reflect.ValueOf(&typed_value).Elem(), column); err != nil {
+			return
+		}
+		item.value = typed_value
+		return
+	}
+
+	if _, err = decoder.next_token(); err != nil {
+		return
+	}
+
+	switch t.Type {
+	case token_word:
+		item = generic_item{
+			word: string(t.Data),
+		}
+		if !t.is_nil() {
+			item.value = item.word
+			item.is_key = !t.Quoted && !is_number(item.word)
+		}
+	case token_open:
+		var items []generic_item
+		for {
+			t, err = decoder.peek_token()
+			if err != nil {
+				return
+			}
+			if t.Type == token_close {
+				break
+			}
+
+			var child generic_item
+			if child, err = decoder.read_generic(column, true); err != nil {
+				return
+			}
+			items = append(items, child)
+		}
+
+		if _, err = decoder.next_token(); err != nil {
+			return
+		}
+		item.value = generic_block(items, column)
+	default:
+		err = syntax_error(t.pos, "expected a value, found %s", t)
+	}
+	return
+}
+
+// Reports whether generic items are keys and values
+func is_keyed(items []generic_item) bool {
+	if len(items) == 0 || len(items)%2 != 0 {
+		return false
+	}
+
+	keys := make(map[string]bool, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		key := items[i]
+		if !key.is_key || keys[key.word] {
+			return false
+		}
+		keys[key.word] = true
+	}
+	return true
+}
+
+// Reports whether a word is a number, which cannot be the key of a keyed block
+func is_number(word string) bool {
+	_, err := strconv.ParseFloat(word, 64)
+	return err == nil
+}
+
+// Reports whether a list of generic values would be decoded again as a keyed block, because every
+// other value, beginning with the first, is a distinct string that is written as a bare key
+func is_keyed_list(value reflect.Value) bool {
+	if value.Type().Elem().Kind() != reflect.Interface || value.Type().Elem().NumMethod() != 0 || value.Len() == 0 || value.Len()%2 != 0 {
+		return false
+	}
+
+	keys := make(map[string]bool, value.Len()/2)
+	for i := 0; i < value.Len(); i += 2 {
+		key := value.Index(i).Elem()
+		if !key.IsValid() || key.Kind() != reflect.String {
+			return false
+		}
+		str := key.String()
+		if !is_bare_word(str) || is_number(str) || is_type_name_string(key) || keys[str] {
+			return false
+		}
+		keys[str] = true
+	}
+	return true
+}
+
+// Returns the generic value of a block
+func generic_block(items []generic_item, column bool) any {
+	if column || !is_keyed(items) {
+		list := make([]any, len(items))
+		for i := range items {
+			list[i] = items[i].value
+		}
+		return list
+	}
+
+	m := make(map[string]any, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		m[items[i].word] = items[i+1].value
+	}
+	return m
+}
+
+// Decodes the remaining rows of the current table into a Table, or an empty interface holding one
+func (decoder *Decoder) decode_table(value reflect.Value) (err error) {
+	if !decoder.tabular {
+		return fmt.Errorf("text: a %s can only hold a tabular document", value.Type())
+	}
+
+	table := Table{
+		Columns: slices.Clone(decoder.columns),
+	}
+
+	for {
+		var t token
+		if t, err = decoder.peek_token(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+				break
+			}
+			return
+		}
+		// Another table begins
+		if t.Type == token_open_table_header {
+			break
+		}
+
+		decoder.in_value = true
+		decoder.push_index(len(table.Rows))
+		var row []any
+		if row, err = decoder.read_generic_row(); err != nil {
+			return
+		}
+		decoder.pop_path()
+		decoder.in_value = false
+
+		table.Rows = append(table.Rows, row)
+	}
+
+	value.Set(reflect.ValueOf(table))
+	return
+}
+
+func (decoder *Decoder) read_generic_row() (row []any, err error) {
+	_, err = decoder.expect_token(token_open, "at start of row")
+	if err != nil {
+		return
+	}
+
+	for i := 0; ; i++ {
+		var t token
+		t, err = decoder.peek_token()
+		if err != nil {
+			return
+		}
+		if t.Type == token_close {
+			break
+		}
+
+		var item generic_item
+		if item, err = decoder.read_generic(true, decoder.is_interface_column(i)); err != nil {
+			return
+		}
+		row = append(row, item.value)
+	}
+
+	_, err = decoder.next_token()
+	return
+}
+
+// Writes a Table as a tabular document
+func (encoder *Encoder) encode_table(table *Table) (err error) {
+	if err = encoder.write_generic_header(table.Columns); err != nil {
+		return
+	}
+
+	for _, row := range table.Rows {
+		if err = encoder.encode_generic_row(row, -1); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Writes the header of a table of generic rows
+func (encoder *Encoder) write_generic_header(columns []string) (err error) {
+	encoder.out.Write([]byte("[ "))
+	for _, column := range columns {
+		// A column may give its type, as in a typed table header
+		name, column_type := split_typed_column(column)
+		if column_type != "" && is_bare_word(name) {
+			encoder.out.WriteString(column)
+		} else if err = encoder.encode_string(column); err != nil {
+			return
+		}
+		encoder.out.Write([]byte(" "))
+	}
+	_, err = encoder.out.Write([]byte("]\n"))
+	return
+}
+
+// Writes a row of generic values. Unless columns is -1, the row must have that many values,
+// or none for a zero row.
+func (encoder *Encoder) encode_generic_row(row []any, columns int) (err error) {
+	if columns >= 0 && len(row) != 0 && len(row) != columns {
+		return fmt.Errorf("text: row has %d values, but the table has %d columns", len(row), columns)
+	}
+
+	if len(row) == 0 {
+		_, err = encoder.out.Write([]byte("{}\n"))
+		return
+	}
+
+	encoder.out.Write([]byte("{ "))
+	for _, column := range row {
+		if err = encoder.encode_column(reflect.ValueOf(&column).Elem()); err != nil {
+			return
+		}
+		encoder.out.Write([]byte(encoder.Indent))
+	}
+	_, err = encoder.out.Write([]byte("}\n"))
+	return
+}
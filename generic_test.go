@@ -0,0 +1,298 @@
+package text_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+func TestDecodeGeneric(t *testing.T) {
+	input := `{
+	Name "Fire Ball"
+	Ranks {
+		1
+		2
+	}
+	Effects {
+		{
+			Radius 5
+			Shape nil
+		}
+	}
+	Row { 1 2 }
+	Empty {}
+}`
+
+	var value any
+	if err := text.Unmarshal([]byte(input), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"Name":  "Fire Ball",
+		"Ranks": []any{"1", "2"},
+		"Effects": []any{
+			map[string]any{"Radius": "5", "Shape": nil},
+		},
+		"Row":   []any{"1", "2"},
+		"Empty": []any{},
+	}
+
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("wrong value %#v", value)
+	}
+
+	// Encoding the generic value produces the same tree
+	data, err := text.Marshal(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded any
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("wrong value after encoding\n%s", data)
+	}
+}
+
+func TestDecodeGenericKeyed(t *testing.T) {
+	// Line breaks do not change whether a block is keyed
+	for _, input := range []string{
+		"{ Name Thrall Level 60 Pets {} }",
+		"{\n\tName Thrall\n\tLevel 60\n\tPets {}\n}",
+		"{ Name\nThrall Level\n60 Pets\n{\n} }",
+	} {
+		var value any
+		if err := text.Unmarshal([]byte(input), &value); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]any{"Name": "Thrall", "Level": "60", "Pets": []any{}}
+		if !reflect.DeepEqual(value, expected) {
+			t.Fatalf("wrong value %#v from %q", value, input)
+		}
+	}
+
+	cases := []struct {
+		input    string
+		expected any
+	}{
+		// Numbers, quoted words, nil and repeated words are not keys
+		{"{ 1 2 3 4 }", []any{"1", "2", "3", "4"}},
+		{"{\n\t\"Name\" Thrall\n}", []any{"Name", "Thrall"}},
+		{"{ nil 1 }", []any{nil, "1"}},
+		{"{ Thrall 60 Thrall 70 }", []any{"Thrall", "60", "Thrall", "70"}},
+		{"{ { A 1 } B }", []any{map[string]any{"A": "1"}, "B"}},
+	}
+	for _, c := range cases {
+		var value any
+		if err := text.Unmarshal([]byte(c.input), &value); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(value, c.expected) {
+			t.Fatalf("wrong value %#v from %q", value, c.input)
+		}
+	}
+
+	// Empty lists and lists of numbers are decoded again as lists
+	var value any = map[string]any{"Ranks": []any{"1", "2"}, "Pets": []any{}}
+	data, err := text.Marshal(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded any
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Fatalf("wrong value %#v from\n%s", decoded, data)
+	}
+}
+
+func TestDecodeGenericTable(t *testing.T) {
+	input := `[ ID Name Ranks ]
+{ 1 "Fire Ball" { 1 2 } }
+{ 2 Frostbolt nil }
+`
+
+	var value any
+	if err := text.Unmarshal([]byte(input), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := text.Table{
+		Columns: []string{"ID", "Name", "Ranks"},
+		Rows: [][]any{
+			{"1", "Fire Ball", []any{"1", "2"}},
+			{"2", "Frostbolt", nil},
+		},
+	}
+
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("wrong value %#v", value)
+	}
+
+	var out strings.Builder
+	encoder := text.NewEncoder(&out)
+	encoder.Indent = " "
+	if err := encoder.Encode(expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != input {
+		t.Fatalf("wrong encoding\n%s", out.String())
+	}
+
+	// Rows can also be decoded one at a time
+	decoder := text.NewDecoder(strings.NewReader(input))
+	var row generic_spell_row
+	if err := decoder.Decode(&row); err != nil {
+		t.Fatal(err)
+	}
+	var generic_row any
+	if err := decoder.Decode(&generic_row); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic_row, expected.Rows[1]) {
+		t.Fatalf("wrong row %#v", generic_row)
+	}
+}
+
+type generic_spell_row struct {
+	ID    uint32
+	Name  string
+	Ranks []uint32
+}
+
+type generic_holder struct {
+	Effect any
+	Label  any
+}
+
+func TestGenericRegisteredTypes(t *testing.T) {
+	holder := generic_holder{
+		Effect: damage_effect(14),
+		Label:  "DamageEffect",
+	}
+
+	data, err := text.Marshal(&holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "{\n\tEffect DamageEffect 14\n\tLabel \"DamageEffect\"\n}\n" {
+		t.Fatalf("wrong encoding\n%s", data)
+	}
+
+	var decoded generic_holder
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, holder) {
+		t.Fatalf("wrong value %#v", decoded)
+	}
+}
+
+func TestGenericTypedValues(t *testing.T) {
+	// A registered type name and its value are one generic value
+	var value any
+	if err := text.Unmarshal([]byte("{ Name Aura Effect AuraEffect { Radius 5 } }"), &value); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"Name": "Aura", "Effect": &aura_effect{Radius: 5}}
+	if !reflect.DeepEqual(value, expected) {
+		t.Fatalf("wrong value %#v", value)
+	}
+
+	input := `[ ID Effect ]
+{ 1 AuraEffect { 0 5 } }
+{ 2 DamageEffect 14 }
+`
+	var table any
+	if err := text.Unmarshal([]byte(input), &table); err != nil {
+		t.Fatal(err)
+	}
+	expected_table := text.Table{
+		Columns: []string{"ID", "Effect"},
+		Rows: [][]any{
+			{"1", &aura_effect{Radius: 5}},
+			{"2", damage_effect(14)},
+		},
+	}
+	if !reflect.DeepEqual(table, expected_table) {
+		t.Fatalf("wrong value %#v", table)
+	}
+}
+
+func TestGenericListRoundTrip(t *testing.T) {
+	// Lists that look like keys and values are written so that they are decoded again as lists
+	var value any = map[string]any{
+		"Pairs": []any{"a", "b"},
+		"Names": []any{"Thrall", "Jaina", "Thrall", "Jaina"},
+	}
+	data, err := text.Marshal(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\n\tNames\n\t{\n\t\tThrall\n\t\tJaina\n\t\tThrall\n\t\tJaina\n\t}\n\tPairs\n\t{\n\t\t\"a\"\n\t\tb\n\t}\n}\n" {
+		t.Fatalf("wrong encoding\n%s", data)
+	}
+	var decoded any
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Fatalf("wrong value %#v from\n%s", decoded, data)
+	}
+
+	// Maps that are empty or have keys that cannot be bare keys are decoded again as lists
+	for _, m := range []map[string]any{
+		{},
+		{"1": "a"},
+		{"Fire Ball": "a"},
+	} {
+		value = m
+		if data, err = text.Marshal(&value); err != nil {
+			t.Fatal(err)
+		}
+		if err = text.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := decoded.([]any); !ok {
+			t.Fatalf("wrong value %#v from\n%s", decoded, data)
+		}
+	}
+}
+
+func TestBeginGenericTable(t *testing.T) {
+	var out strings.Builder
+	encoder := text.NewEncoder(&out)
+	encoder.Indent = " "
+	if err := encoder.BeginGenericTable([]string{"ID", "Name"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]any{{"1", "Fire Ball"}, {"2", nil}} {
+		if err := encoder.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Encode([]any{"3"}); err == nil {
+		t.Fatal("expected an error for a row with too few values")
+	}
+	if err := encoder.EndTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[ ID Name ]\n{ 1 \"Fire Ball\" }\n{ 2 nil }\n"
+	if out.String() != expected {
+		t.Fatalf("wrong encoding\n%s", out.String())
+	}
+}
@@ -1,5 +1,3 @@
 module github.com/Gophercraft/text
 
-go 1.22.0
-
-require github.com/davecgh/go-spew v1.1.1
+go 1.23.0
@@ -0,0 +1,198 @@
+package text
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A TableIndex records where each row of a table begins, so that single rows can be decoded
+// without reading the rows before them. An index can be saved alongside its table with Marshal,
+// and loaded again with Unmarshal.
+type TableIndex struct {
+	// The columns of the table header
+	Columns []string
+	// The type of each column, if the table header is typed
+	ColumnTypes []string `text:",omitempty"`
+	// The offset of each row in the input
+	Rows []int64
+	// The offset just past the end of the last row
+	End int64
+	// The column that rows are looked up by, if any
+	KeyColumn string
+	// The row number for each word in the key column. Zero rows, written as {}, have no key.
+	Keys map[string]int
+}
+
+// ErrRowNotFound is returned by TableReader.Get when no row has the requested key.
+var ErrRowNotFound = errors.New("text: row not found")
+
+// BuildTableIndex reads the first table in r and returns an index of its rows.
+// If key_column is not empty, rows can also be looked up by their value in that column,
+// which must be a word that is different in every row.
+func BuildTableIndex(r io.Reader, key_column string) (index *TableIndex, err error) {
+	decoder := NewDecoder(r)
+	if err = decoder.NextTable(); err != nil {
+		return
+	}
+
+	index = &TableIndex{
+		Columns:     decoder.Columns(),
+		ColumnTypes: decoder.ColumnTypes(),
+		End:         decoder.pos.offset,
+		KeyColumn:   key_column,
+	}
+
+	key := -1
+	if key_column != "" {
+		if key = slices.Index(index.Columns, key_column); key < 0 {
+			return nil, fmt.Errorf("text: table has no column %q", key_column)
+		}
+		index.Keys = make(map[string]int)
+	}
+
+	for decoder.More() {
+		if err = decoder.index_row(index, key); err != nil {
+			return nil, decoder.decode_error(err)
+		}
+	}
+	return
+}
+
+// Adds the next row to an index, along with its value in the key column if key is not -1
+func (decoder *Decoder) index_row(index *TableIndex, key int) (err error) {
+	decoder.in_value = true
+	defer func() {
+		decoder.in_value = false
+	}()
+	decoder.path = decoder.path[:0]
+	decoder.push_index(len(index.Rows))
+
+	var open token
+	if open, err = decoder.expect_token(token_open, "at start of row"); err != nil {
+		return
+	}
+
+	has_key := key < 0
+	column := 0
+	for ; ; column++ {
+		var t token
+		if t, err = decoder.peek_token(); err != nil {
+			return
+		}
+		if t.Type == token_close {
+			break
+		}
+
+		if column == key {
+			if t.Type != token_word {
+				return syntax_error(t.pos, "expected a word in key column %s, found %s", index.KeyColumn, t)
+			}
+			word := string(t.Data)
+			if _, ok := index.Keys[word]; ok {
+				return syntax_error(t.pos, "duplicate key %s in column %s", FormatWord(word), index.KeyColumn)
+			}
+			index.Keys[word] = len(index.Rows)
+			has_key = true
+		}
+
+		if _, err = decoder.skip_value(false, decoder.is_interface_column(column)); err != nil {
+			return
+		}
+	}
+
+	var close token
+	if close, err = decoder.next_token(); err != nil {
+		return
+	}
+	// A zero row has no values, so it is left out of the keys
+	if !has_key && column > 0 {
+		return syntax_error(close.pos, "row has no value in key column %s", index.KeyColumn)
+	}
+
+	index.Rows = append(index.Rows, open.pos.offset)
+	index.End = close.end
+	return
+}
+
+// A TableReader decodes rows of an indexed table from anywhere in its input.
+type TableReader struct {
+	input   io.ReaderAt
+	index   *TableIndex
+	decoder *Decoder
+
+	// If true, columns that have no corresponding struct field are skipped.
+	SkipUnknownFields bool
+}
+
+// NewTableReader returns a reader for the table in input described by index.
+// Decode starts at the first row until Seek is called.
+func NewTableReader(input io.ReaderAt, index *TableIndex) *TableReader {
+	return &TableReader{
+		input: input,
+		index: index,
+	}
+}
+
+// Len returns the number of rows in the table.
+func (reader *TableReader) Len() int {
+	return len(reader.index.Rows)
+}
+
+// Seek moves the reader to a row, so that the next call to Decode reads it. Seeking to the row
+// just past the last one moves the reader to the end of the table.
+func (reader *TableReader) Seek(row int) (err error) {
+	if row < 0 || row > len(reader.index.Rows) {
+		return fmt.Errorf("text: row %d is out of range for a table with %d rows", row, len(reader.index.Rows))
+	}
+
+	offset := reader.index.End
+	if row < len(reader.index.Rows) {
+		offset = reader.index.Rows[row]
+	}
+
+	// Line numbers in errors count from the row, but offsets are those of the whole input
+	decoder := NewDecoder(io.NewSectionReader(reader.input, offset, reader.index.End-offset))
+	decoder.base = offset
+	decoder.pos.offset = offset
+	decoder.tabular = true
+	decoder.columns = slices.Clone(reader.index.Columns)
+	decoder.column_types = slices.Clone(reader.index.ColumnTypes)
+	if len(decoder.column_types) != len(decoder.columns) {
+		decoder.column_types = make([]string, len(decoder.columns))
+	}
+	reader.decoder = decoder
+	return
+}
+
+// Decode reads the next row and stores it in the value pointed to by value.
+// After the last row, Decode returns an error wrapping io.EOF.
+func (reader *TableReader) Decode(value any) (err error) {
+	if reader.decoder == nil {
+		if err = reader.Seek(0); err != nil {
+			return
+		}
+	}
+
+	reader.decoder.SkipUnknownFields = reader.SkipUnknownFields
+	return reader.decoder.Decode(value)
+}
+
+// Get decodes the row whose word in the key column is key. If there is no such row,
+// Get returns an error wrapping ErrRowNotFound.
+func (reader *TableReader) Get(key string, value any) (err error) {
+	if reader.index.Keys == nil {
+		return fmt.Errorf("text: table index has no key column")
+	}
+
+	row, ok := reader.index.Keys[key]
+	if !ok {
+		return fmt.Errorf("%w: %s %s", ErrRowNotFound, reader.index.KeyColumn, FormatWord(key))
+	}
+
+	if err = reader.Seek(row); err != nil {
+		return
+	}
+	return reader.Decode(value)
+}
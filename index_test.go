@@ -0,0 +1,121 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+func TestTableIndex(t *testing.T) {
+	var buf bytes.Buffer
+	items := bench_items(100)
+	if err := text.EncodeAll(&buf, items, text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	// Another table follows, which is not part of the index
+	buf.WriteString("[ Name Level ]\n{ Thrall 60 }\n")
+
+	index, err := text.BuildTableIndex(bytes.NewReader(buf.Bytes()), "ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Rows) != len(items) || len(index.Keys) != len(items) {
+		t.Fatal("wrong number of rows", len(index.Rows))
+	}
+
+	// The index can be kept in a file of its own
+	data, err := text.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded text.TableIndex
+	if err = text.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&loaded, index) {
+		t.Fatal("index changed after encoding")
+	}
+
+	reader := text.NewTableReader(bytes.NewReader(buf.Bytes()), &loaded)
+
+	var item bench_item
+	if err = reader.Get("57", &item); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(item, items[56]) {
+		t.Fatal("got back incorrect row", item)
+	}
+
+	if err = reader.Get("1000", &item); !errors.Is(err, text.ErrRowNotFound) {
+		t.Fatal("expected a missing row, got", err)
+	}
+
+	// Rows are read in order from where the reader was moved to
+	if err = reader.Seek(98); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range items[98:] {
+		var item bench_item
+		if err = reader.Decode(&item); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(item, expected) {
+			t.Fatal("got back incorrect row", item)
+		}
+	}
+	if err = reader.Decode(&item); !errors.Is(err, io.EOF) {
+		t.Fatal("expected the end of the table, got", err)
+	}
+}
+
+func TestTableIndexRows(t *testing.T) {
+	// Zero rows have no key, and a type name and its value are one column
+	input := "[ Effect ID ]\n{ AuraEffect { 0 5 } 1 }\n{}\n{ DamageEffect 14 2 }\n"
+
+	index, err := text.BuildTableIndex(strings.NewReader(input), "ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Rows) != 3 || !reflect.DeepEqual(index.Keys, map[string]int{"1": 0, "2": 2}) {
+		t.Fatal("wrong index", index.Rows, index.Keys)
+	}
+
+	reader := text.NewTableReader(strings.NewReader(input), index)
+	var row index_effect_row
+	if err = reader.Get("2", &row); err != nil {
+		t.Fatal(err)
+	}
+	if row.Effect != damage_effect(14) || row.ID != 2 {
+		t.Fatal("got back incorrect row", row)
+	}
+}
+
+type index_effect_row struct {
+	Effect spell_effect
+	ID     uint32
+}
+
+func TestTableIndexErrors(t *testing.T) {
+	inputs := map[string]string{
+		"[ ID Name ]\n{ 1 a }\n{ 1 b }\n":   "duplicate key 1 in column ID",
+		"[ ID Name ]\n{ { 1 } a }\n":        "expected a word in key column ID",
+		"[ Name ID ]\n{ a 1 }\n{ b }\n":     "row has no value in key column ID",
+		"[ ID Name ]\n{ 1 a }\n{ 2 { b }\n": "unexpected end of input",
+	}
+
+	for input, message := range inputs {
+		_, err := text.BuildTableIndex(strings.NewReader(input), "ID")
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("expected an error containing %q for %q, got %v", message, input, err)
+		}
+	}
+
+	if _, err := text.BuildTableIndex(strings.NewReader("[ ID ]\n"), "Name"); err == nil {
+		t.Fatal("expected an error for a missing key column")
+	}
+}
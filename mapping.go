@@ -0,0 +1,155 @@
+package text
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A ColumnMapping describes how a column of a table header is decoded.
+type ColumnMapping struct {
+	Column string
+	// The name of the field that the column is decoded into.
+	// If empty, the column is unknown, or left out by SelectColumns.
+	Field string
+	// Set if the column matched an alias of the field, rather than its name
+	Alias bool
+	// Set if the column is left out by SelectColumns
+	Skipped bool
+	// The type given in a typed table header, if any
+	Type string
+}
+
+// A TableMapping reports how the columns of a table header are matched to the fields of a row type.
+type TableMapping struct {
+	Columns []ColumnMapping
+	// Fields with no column, which are given their default values
+	Defaults []string
+	// Fields with no column and no default value, which are left as their zero values
+	Missing []string
+}
+
+// Mapping reports how the columns of the current table header are decoded into rows of type row_type.
+// It returns an error if no table header has been read, or if the header cannot be decoded into
+// row_type, such as when a required column is missing.
+func (decoder *Decoder) Mapping(row_type reflect.Type) (mapping *TableMapping, err error) {
+	if !decoder.tabular {
+		return nil, fmt.Errorf("text: no table header has been read")
+	}
+
+	if row_type.Kind() == reflect.Pointer {
+		row_type = row_type.Elem()
+	}
+	if row_type.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("to use tabular decoding, a row must be a struct")
+	}
+
+	var plan *row_plan
+	if plan, err = decoder.get_row_plan(row_type); err != nil {
+		return
+	}
+
+	mapping = &TableMapping{
+		Columns: make([]ColumnMapping, len(decoder.columns)),
+	}
+	for i, name := range decoder.columns {
+		column := ColumnMapping{
+			Column:  name,
+			Skipped: plan.skip[i],
+			Type:    decoder.column_types[i],
+		}
+		if f := plan.columns[i]; f != nil {
+			column.Field = f.name
+			column.Alias = f.name != name
+		}
+		mapping.Columns[i] = column
+	}
+	for _, d := range plan.defaults {
+		mapping.Defaults = append(mapping.Defaults, d.field.name)
+	}
+	for _, f := range plan.missing {
+		mapping.Missing = append(mapping.Missing, f.name)
+	}
+	return
+}
+
+// The default value of a field, for rows of a table with no column for it
+type row_default struct {
+	field *field
+	// The decoded default value, if it can be shared by every row
+	value reflect.Value
+}
+
+func new_row_default(row_type reflect.Type, f *field) (d row_default, err error) {
+	d.field = f
+
+	value := reflect.New(row_type.FieldByIndex(f.index).Type).Elem()
+	if err = decode_default(f, value); err != nil {
+		return
+	}
+
+	// Values that refer to memory are decoded again for each row, so that rows do not share it
+	if is_shareable(value.Type()) {
+		d.value = value
+	}
+	return
+}
+
+// Sets the field of a row to its default value
+func (d *row_default) set(row reflect.Value) (err error) {
+	value := field_value_alloc(row, d.field)
+	if d.value.IsValid() {
+		value.Set(d.value)
+		return
+	}
+	return decode_default(d.field, value)
+}
+
+// Decodes the default value of a field as if it were written in a column
+func decode_default(f *field, value reflect.Value) (err error) {
+	// The default is decoded straight from the tag, without an input stream
+	decoder := &Decoder{
+		buffer:    []byte(f.default_value),
+		input_err: io.EOF,
+		pos: position{
+			line:   1,
+			column: 1,
+		},
+	}
+
+	decoder.in_value = true
+	if err = decoder.decode_column(value); err == nil {
+		decoder.in_value = false
+		var t token
+		if t, err = decoder.next_token(); err == nil {
+			err = syntax_error(t.pos, "unexpected %s after value", t)
+		} else if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		err = fmt.Errorf("text: invalid default value for field %s: %w", f.name, err)
+	}
+	return
+}
+
+// Reports whether copies of a value of type t share no memory
+func is_shareable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return is_shareable(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if !is_shareable(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,230 @@
+package text
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelOptions configure DecodeTableParallel and DecodeTableUnordered.
+type ParallelOptions struct {
+	// The number of goroutines decoding rows. If zero, this is runtime.GOMAXPROCS(0)
+	Workers int
+	// If true, columns that have no corresponding struct field are skipped.
+	SkipUnknownFields bool
+	// If not empty, only these columns are decoded, as with Decoder.SelectColumns
+	Columns []string
+}
+
+// The number of rows handed to a worker at a time
+const parallel_chunk_rows = 256
+
+// Consecutive rows of a table, split from the input so that they can be decoded on their own
+type row_chunk struct {
+	// The chunk's place in the table
+	number int
+	// The row number of the first row
+	first int
+	rows  int
+	// The source text of the rows, beginning at pos
+	data []byte
+	pos  position
+}
+
+// The error that stops a parallel decode. If several rows fail, the error of the first one
+// is kept, as far as the rows that were decoded go.
+type parallel_failure struct {
+	mutex  sync.Mutex
+	chunk  int
+	err    error
+	failed atomic.Bool
+}
+
+func (failure *parallel_failure) set(chunk int, err error) {
+	failure.mutex.Lock()
+	if failure.err == nil || chunk < failure.chunk {
+		failure.chunk = chunk
+		failure.err = err
+	}
+	failure.mutex.Unlock()
+	failure.failed.Store(true)
+}
+
+// DecodeTableParallel decodes the rows of the first table in r using several goroutines,
+// and returns them in their original order.
+func DecodeTableParallel[T any](r io.Reader, opts ParallelOptions) (values []T, err error) {
+	var (
+		mutex   sync.Mutex
+		results [][]T
+	)
+
+	err = decode_parallel(r, opts, func(chunk *row_chunk, rows []T) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if chunk.number >= len(results) {
+			results = slices.Grow(results, chunk.number+1-len(results))[:chunk.number+1]
+		}
+		results[chunk.number] = rows
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	values = slices.Concat(results...)
+	return
+}
+
+// DecodeTableUnordered decodes the rows of the first table in r using several goroutines, calling fn
+// with each row and its row number as soon as it is decoded. fn is called from several goroutines at
+// once, and rows are not passed to it in order. If fn returns an error, decoding stops and
+// DecodeTableUnordered returns it.
+func DecodeTableUnordered[T any](r io.Reader, opts ParallelOptions, fn func(row int, value T) error) (err error) {
+	return decode_parallel(r, opts, func(chunk *row_chunk, rows []T) (err error) {
+		for i := range rows {
+			if err = fn(chunk.first+i, rows[i]); err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Splits the rows of the first table in r into chunks, which are decoded by workers and given to handle
+func decode_parallel[T any](r io.Reader, opts ParallelOptions, handle func(chunk *row_chunk, rows []T) error) (err error) {
+	decoder := NewDecoder(r)
+	if err = decoder.NextTable(); err != nil {
+		// Input without a table has no rows
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		chunks  = make(chan row_chunk, workers)
+		wait    sync.WaitGroup
+		failure parallel_failure
+	)
+
+	for range workers {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for chunk := range chunks {
+				// Leave the remaining chunks once a row has failed
+				if failure.failed.Load() {
+					continue
+				}
+
+				rows, err := decode_chunk[T](&chunk, decoder, &opts)
+				if err == nil {
+					err = handle(&chunk, rows)
+				}
+				if err != nil {
+					failure.set(chunk.number, err)
+				}
+			}
+		}()
+	}
+
+	for number, first := 0, 0; !failure.failed.Load(); number++ {
+		var chunk row_chunk
+		if chunk, err = decoder.read_chunk(parallel_chunk_rows); err != nil {
+			if !errors.Is(err, io.EOF) {
+				failure.set(number, err)
+			}
+			break
+		}
+
+		chunk.number = number
+		chunk.first = first
+		first += chunk.rows
+		chunks <- chunk
+	}
+
+	close(chunks)
+	wait.Wait()
+	return failure.err
+}
+
+// Reads up to max_rows rows of the current table without decoding them.
+// At the end of the table, read_chunk returns an error wrapping io.EOF.
+func (decoder *Decoder) read_chunk(max_rows int) (chunk row_chunk, err error) {
+	defer func() {
+		decoder.in_value = false
+		decoder.capturing = false
+		err = decoder.decode_error(err)
+	}()
+
+	var t token
+	if t, err = decoder.peek_token(); err != nil {
+		return
+	}
+	if t.Type == token_open_table_header {
+		err = io.EOF
+		return
+	}
+
+	chunk.pos = t.pos
+	decoder.begin_capture()
+
+	for chunk.rows < max_rows {
+		if chunk.rows > 0 {
+			if t, err = decoder.peek_token(); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+					break
+				}
+				return
+			}
+			if t.Type == token_open_table_header {
+				break
+			}
+		}
+
+		decoder.in_value = true
+		if t.Type != token_open {
+			err = syntax_error(t.pos, "expected '{' at start of row, found %s", t)
+			return
+		}
+		if _, err = decoder.skip_value(false, false); err != nil {
+			return
+		}
+		decoder.in_value = false
+		chunk.rows++
+	}
+
+	// Nothing has been peeked after the last row, so the input is consumed up to its end
+	chunk.data = decoder.end_capture(chunk.pos.offset, decoder.pos.offset)
+	return
+}
+
+// Decodes the rows of a chunk, using the table header read by header
+func decode_chunk[T any](chunk *row_chunk, header *Decoder, opts *ParallelOptions) (rows []T, err error) {
+	decoder := NewDecoder(bytes.NewReader(chunk.data))
+	decoder.pos = chunk.pos
+	decoder.base = chunk.pos.offset
+	decoder.tabular = true
+	decoder.columns = header.columns
+	decoder.column_types = header.column_types
+	decoder.SkipUnknownFields = opts.SkipUnknownFields
+	decoder.SelectColumns(opts.Columns...)
+
+	rows = make([]T, chunk.rows)
+	for i := range rows {
+		if err = decoder.Decode(&rows[i]); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+func TestDecodeTableParallel(t *testing.T) {
+	var buf bytes.Buffer
+	items := bench_items(1000)
+	if err := text.EncodeAll(&buf, items, text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	// Comments and blocks that look like row boundaries do not split rows
+	buf.WriteString("{ 1001 \"} {\" 0 0 0 /* } { */ { 1 2 } false }\n")
+	items = append(items, bench_item{ID: 1001, Name: "} {", Stats: []int32{1, 2}})
+	// Only the first table is decoded
+	buf.WriteString("[ Name Level ]\n{ Thrall 60 }\n")
+
+	decoded, err := text.DecodeTableParallel[bench_item](bytes.NewReader(buf.Bytes()), text.ParallelOptions{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, items) {
+		t.Fatal("got back incorrect rows")
+	}
+
+	var (
+		mutex     sync.Mutex
+		unordered = make([]bench_item, len(items))
+	)
+	err = text.DecodeTableUnordered(bytes.NewReader(buf.Bytes()), text.ParallelOptions{Workers: 4}, func(row int, item bench_item) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		unordered[row] = item
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unordered, items) {
+		t.Fatal("got back incorrect rows")
+	}
+}
+
+func TestDecodeTableParallelErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := text.EncodeAll(&buf, bench_items(1000), text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Replace(buf.Bytes(), []byte("{ 600 "), []byte("{ x "), 1)
+	data = bytes.Replace(data, []byte("{ 900 "), []byte("{ y "), 1)
+
+	// The error of the first row that fails is returned, with its position in the input
+	_, err := text.DecodeTableParallel[bench_item](bytes.NewReader(data), text.ParallelOptions{Workers: 4})
+	var decode_error *text.DecodeError
+	if !errors.As(err, &decode_error) {
+		t.Fatal("expected a decoding error, got", err)
+	}
+	if decode_error.Line != 601 || decode_error.Offset != int64(bytes.Index(data, []byte("{ x "))+2) {
+		t.Fatal("wrong position for error", err)
+	}
+
+	stop := errors.New("stop")
+	err = text.DecodeTableUnordered(bytes.NewReader(buf.Bytes()), text.ParallelOptions{}, func(row int, item bench_item) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatal("expected the error from the callback, got", err)
+	}
+
+	// A row cut short is a syntax error
+	_, err = text.DecodeTableParallel[bench_item](bytes.NewReader(buf.Bytes()[:buf.Len()-4]), text.ParallelOptions{})
+	if err == nil {
+		t.Fatal("expected an error for a truncated row")
+	}
+}
@@ -0,0 +1,98 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type pointer_config struct {
+	Rate  float32
+	Label string
+}
+
+type pointer_record struct {
+	ID     uint32
+	Level  *uint32
+	Config *pointer_config
+	Names  []*string
+}
+
+func new_value[T any](v T) *T {
+	return &v
+}
+
+var pointer_records = []pointer_record{
+	{
+		ID:     1,
+		Level:  new_value[uint32](60),
+		Config: &pointer_config{Rate: 0.5, Label: "nil"},
+		Names:  []*string{new_value("a"), nil, new_value("nil")},
+	},
+	{
+		ID: 2,
+	},
+}
+
+func TestPointerKeyed(t *testing.T) {
+	for _, record := range pointer_records {
+		data, err := text.Marshal(&record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded pointer_record
+		if err = text.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(record, decoded) {
+			t.Fatal("got back incorrect record from", string(data))
+		}
+	}
+}
+
+func TestPointerTable(t *testing.T) {
+	expected := `[ ID Level Config Names ]
+{ 1 60 { 0.5 "nil" } { a nil "nil" } }
+{ 2 nil nil {} }
+`
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+	encoder.Tabular = true
+
+	for _, record := range pointer_records {
+		if err := encoder.Encode(&record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	decoder := text.NewDecoder(strings.NewReader(expected))
+	var records []pointer_record
+	for {
+		var record pointer_record
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	if !reflect.DeepEqual(pointer_records, records) {
+		t.Fatal("got back incorrect records")
+	}
+}
@@ -0,0 +1,332 @@
+package text
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// RawValue is the source text of a word or bracketed block, exactly as it appears in the input,
+// including any comments inside it. It can be used to delay decoding part of a document until
+// its type is known, or to keep values that have no corresponding struct field.
+//
+// When encoded, a RawValue is written as it is, except that the indentation of each line
+// is adjusted to fit its new surroundings. A table row can be decoded into a []RawValue,
+// which holds the source text of each column, including the type name of an interface value.
+type RawValue []byte
+
+var (
+	raw_value_type = reflect.TypeFor[RawValue]()
+	raw_row_type   = reflect.TypeFor[[]RawValue]()
+)
+
+// Starts keeping the input in the buffer, beginning with a token that has already been peeked.
+// Nothing happens if a capture is already in progress.
+func (decoder *Decoder) begin_capture() {
+	if decoder.capturing {
+		return
+	}
+	decoder.capturing = true
+	decoder.capture_start = decoder.pos.offset
+
+	decoder.drop_peeked_comment()
+	if decoder.has_peeked {
+		decoder.capture_start = decoder.peeked.pos.offset
+	}
+}
+
+// Stops capturing and returns a copy of the input between the offsets start and end
+func (decoder *Decoder) end_capture(start, end int64) RawValue {
+	decoder.capturing = false
+	return RawValue(bytes.Clone(decoder.buffer[start-decoder.base : end-decoder.base]))
+}
+
+// Consumes a word or an entire bracketed block without decoding it. If typed is true, a bare
+// registered type name is consumed together with the value after it, as in decode_interface.
+// If capture is true, the source text of the value is returned.
+func (decoder *Decoder) skip_value(capture, typed bool) (raw RawValue, err error) {
+	var (
+		start token
+		first token
+		last  token
+	)
+
+	if capture {
+		decoder.begin_capture()
+		defer func() {
+			decoder.capturing = false
+		}()
+	}
+
+	first, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+	start = first
+	if typed && is_type_name(first) {
+		if first, err = decoder.next_token(); err != nil {
+			return
+		}
+	}
+
+	switch first.Type {
+	case token_word:
+		last = first
+	case token_open:
+		for depth := 1; depth > 0; {
+			last, err = decoder.next_token()
+			if err != nil {
+				return
+			}
+
+			switch last.Type {
+			case token_open:
+				depth++
+			case token_close:
+				depth--
+			case token_word:
+			default:
+				err = syntax_error(last.pos, "unexpected %s inside block", last)
+				return
+			}
+		}
+	default:
+		err = syntax_error(first.pos, "expected a value, found %s", first)
+		return
+	}
+
+	if capture {
+		raw = decoder.end_capture(start.pos.offset, last.end)
+	}
+	return
+}
+
+// Reads a table row without decoding it, returning the source text of each column.
+// As in decode_interface, a bare type name and the value after it are kept together as one column.
+func (decoder *Decoder) read_raw_row() (row []RawValue, err error) {
+	if _, err = decoder.expect_token(token_open, "at start of row"); err != nil {
+		return
+	}
+
+	for i := 0; ; i++ {
+		var t token
+		if t, err = decoder.peek_token(); err != nil {
+			return
+		}
+		if t.Type == token_close {
+			break
+		}
+
+		var type_name []byte
+		if is_type_name(t) && decoder.is_interface_column(i) {
+			if _, err = decoder.next_token(); err != nil {
+				return
+			}
+			type_name = append(bytes.Clone(t.Data), ' ')
+		}
+
+		var raw RawValue
+		if raw, err = decoder.skip_value(true, false); err != nil {
+			return
+		}
+		row = append(row, append(type_name, raw...))
+	}
+
+	_, err = decoder.next_token()
+	return
+}
+
+// Reports whether t is the bare name of a registered type, which is followed by a value of that type
+func is_type_name(t token) bool {
+	if t.Type != token_word || t.Quoted || t.is_nil() {
+		return false
+	}
+	_, ok := registered_type(string(t.Data))
+	return ok
+}
+
+// Reports whether a column can hold an interface value. Without a typed table header, any column can.
+func (decoder *Decoder) is_interface_column(column int) bool {
+	if column >= len(decoder.column_types) || decoder.column_types[column] == "" {
+		return true
+	}
+	return strings.TrimLeft(decoder.column_types[column], "*") == "any"
+}
+
+func (decoder *Decoder) decode_raw(value reflect.Value) (err error) {
+	var raw RawValue
+	raw, err = decoder.skip_value(true, true)
+	if err != nil {
+		return
+	}
+
+	value.SetBytes(raw)
+	return
+}
+
+// Validate returns an error unless raw holds exactly one word or bracketed block,
+// which may follow a registered type name.
+func (raw RawValue) Validate() (err error) {
+	decoder := NewDecoder(bytes.NewReader(raw))
+	decoder.in_value = true
+
+	if _, err = decoder.skip_value(false, true); err != nil {
+		return
+	}
+
+	decoder.in_value = false
+	var t token
+	if t, err = decoder.next_token(); err == nil {
+		return syntax_error(t.pos, "unexpected %s after value", t)
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return
+}
+
+// Reports whether a raw value is a bracketed block
+func (raw RawValue) is_block() bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && raw[0] == '{'
+}
+
+// Splits a raw value into lines at the line breaks that are not inside quoted words
+func raw_lines(raw []byte) (lines [][]byte) {
+	var (
+		start       int
+		token_start = true
+		in_comment  bool
+	)
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+
+		if in_comment {
+			if c == '\n' {
+				lines = append(lines, raw[start:i])
+				start = i + 1
+			} else if c == '*' && i+1 < len(raw) && raw[i+1] == '/' {
+				i++
+				in_comment = false
+				token_start = true
+			}
+			continue
+		}
+
+		switch {
+		case c == '\n':
+			lines = append(lines, raw[start:i])
+			start = i + 1
+			token_start = true
+		case c == ' ' || c == '\t' || c == '\r':
+			token_start = true
+		case !token_start:
+			// Inside a bare word
+		case c == '"':
+			for i++; i < len(raw) && raw[i] != '"'; i++ {
+				if raw[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(raw) && raw[i+1] == '/':
+			for i+1 < len(raw) && raw[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(raw) && raw[i+1] == '*':
+			i++
+			in_comment = true
+		case c == '{' || c == '}':
+		default:
+			token_start = false
+		}
+	}
+
+	return append(lines, raw[start:])
+}
+
+// Writes a raw value at depth. The indentation of each line is replaced, keeping the
+// indentation of lines relative to the line that closes the value.
+func (encoder *Encoder) encode_raw(depth int, raw RawValue) (err error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return encoder.encode_nil()
+	}
+
+	if err = raw.Validate(); err != nil {
+		return fmt.Errorf("text: invalid RawValue: %w", err)
+	}
+
+	lines := raw_lines(raw)
+	last := lines[len(lines)-1]
+	base := last[:len(last)-len(bytes.TrimLeft(last, " \t"))]
+
+	for i, line := range lines {
+		line = bytes.TrimRight(line, " \t\r")
+		if i > 0 {
+			encoder.out.Write([]byte("\n"))
+			if len(line) == 0 {
+				continue
+			}
+			if trimmed, ok := bytes.CutPrefix(line, base); ok {
+				line = trimmed
+			} else {
+				line = bytes.TrimLeft(line, " \t")
+			}
+			encoder.writeIndentation(depth)
+		}
+		if _, err = encoder.out.Write(line); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Writes a raw value in a table row as it is
+func (encoder *Encoder) encode_raw_column(raw RawValue) (err error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return encoder.encode_nil()
+	}
+
+	if err = raw.Validate(); err != nil {
+		return fmt.Errorf("text: invalid RawValue: %w", err)
+	}
+
+	_, err = encoder.out.Write(raw)
+	return
+}
+
+// Writes the entries of a struct's catch-all field at depth, in order of their keys.
+// Entries that would be confused with a known field are left out.
+func (encoder *Encoder) encode_unknown_fields(depth int, value reflect.Value, fields *struct_fields) (err error) {
+	if fields.unknown == nil {
+		return
+	}
+
+	unknown, ok := field_value(value, fields.unknown)
+	if !ok || unknown.Len() == 0 {
+		return
+	}
+
+	keys := unknown.MapKeys()
+	sort_values(keys)
+
+	for _, key := range keys {
+		if _, known := fields.lookup(key.String()); known {
+			continue
+		}
+
+		encoder.writeIndentation(depth)
+		if err = encoder.encode_string(key.String()); err != nil {
+			return
+		}
+		if err = encoder.encode_keyed_value(depth, unknown.MapIndex(key)); err != nil {
+			return
+		}
+	}
+	return
+}
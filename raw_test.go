@@ -0,0 +1,198 @@
+package text_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type raw_plugin struct {
+	Name   string
+	Config text.RawValue
+}
+
+type raw_plugins struct {
+	Plugins []raw_plugin
+	Order   []text.RawValue
+	Main    *text.RawValue
+}
+
+type raw_plugin_config struct {
+	Port    uint16
+	Message string
+}
+
+func TestDecodeRawValue(t *testing.T) {
+	input := `{
+	Plugins {
+		{
+			Name chat
+			Config {
+				Port 8085 // the default
+				Message "hello
+	world"
+			}
+		}
+	}
+	Order { "chat" { a b } auth }
+	Main "chat"
+}`
+
+	var plugins raw_plugins
+	if err := text.Unmarshal([]byte(input), &plugins); err != nil {
+		t.Fatal(err)
+	}
+
+	config := "{\n\t\t\t\tPort 8085 // the default\n\t\t\t\tMessage \"hello\n\tworld\"\n\t\t\t}"
+	if len(plugins.Plugins) != 1 || string(plugins.Plugins[0].Config) != config {
+		t.Fatalf("wrong raw value %q", plugins.Plugins[0].Config)
+	}
+
+	if len(plugins.Order) != 3 || string(plugins.Order[0]) != `"chat"` || string(plugins.Order[1]) != "{ a b }" || string(plugins.Order[2]) != "auth" {
+		t.Fatalf("wrong raw values %q", plugins.Order)
+	}
+
+	if plugins.Main == nil || string(*plugins.Main) != `"chat"` {
+		t.Fatal("wrong raw pointer", plugins.Main)
+	}
+
+	// The raw value can be decoded once its type is known
+	var plugin_config raw_plugin_config
+	if err := text.Unmarshal(plugins.Plugins[0].Config, &plugin_config); err != nil {
+		t.Fatal(err)
+	}
+	if plugin_config.Port != 8085 || plugin_config.Message != "hello\n\tworld" {
+		t.Fatal("wrong config", plugin_config)
+	}
+}
+
+func TestEncodeRawValue(t *testing.T) {
+	plugin := raw_plugin{
+		Name:   "chat",
+		Config: text.RawValue("{\n\t\t\t\tPort 8085 // the default\n\t\t\t\tMessage \"hello\n\tworld\"\n\t\t\t\tRates {\n\t\t\t\t\t1 2\n\t\t\t\t}\n\t\t\t}"),
+	}
+
+	data, err := text.Marshal(&plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n\tName chat\n\tConfig\n\t{\n\t\tPort 8085 // the default\n\t\tMessage \"hello\n\tworld\"\n\t\tRates {\n\t\t\t1 2\n\t\t}\n\t}\n}\n"
+	if string(data) != expected {
+		t.Fatalf("wrong encoding\n%s", data)
+	}
+
+	plugin.Config = text.RawValue("{ unbalanced")
+	if _, err = text.Marshal(&plugin); err == nil {
+		t.Fatal("expected an error for an invalid raw value")
+	}
+}
+
+func TestUnknownFieldsRoundTrip(t *testing.T) {
+	input := `{
+	ID 12
+	Visual {
+		Model "fire.m2" // a newer field
+	}
+	Cooldown 1500
+}
+`
+
+	var spell unknown_spell_extra
+	if err := text.Unmarshal([]byte(input), &spell); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := text.Marshal(&spell)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+	ID 12
+	Cooldown 1500
+	Visual
+	{
+		Model "fire.m2" // a newer field
+	}
+}
+`
+	if string(data) != expected {
+		t.Fatalf("wrong encoding\n%s", data)
+	}
+}
+
+func TestDecodeRawRow(t *testing.T) {
+	input := `[ ID Name Ranks Effect ]
+{ 133 "Fire Ball" { 1 /* first */ 2 } AuraEffect { 3 8 } }
+{ 116 Frostbolt nil DamageEffect 14 }
+`
+
+	decoder := text.NewDecoder(strings.NewReader(input))
+	var rows [][]text.RawValue
+	for {
+		var row []text.RawValue
+		if err := decoder.Decode(&row); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+
+	expected := [][]text.RawValue{
+		{text.RawValue("133"), text.RawValue(`"Fire Ball"`), text.RawValue("{ 1 /* first */ 2 }"), text.RawValue("AuraEffect { 3 8 }")},
+		{text.RawValue("116"), text.RawValue("Frostbolt"), text.RawValue("nil"), text.RawValue("DamageEffect 14")},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("wrong rows %q", rows)
+	}
+
+	// Raw columns are written back as they were
+	var out strings.Builder
+	encoder := text.NewEncoder(&out)
+	encoder.Indent = " "
+	if err := encoder.BeginGenericTable(decoder.Columns()); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		generic_row := make([]any, len(row))
+		for i := range row {
+			generic_row[i] = row[i]
+		}
+		if err := encoder.Encode(generic_row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.EndTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != input {
+		t.Fatalf("wrong encoding\n%s", out.String())
+	}
+}
+
+func TestValidateRawValue(t *testing.T) {
+	for raw, valid := range map[string]bool{
+		"word":                        true,
+		"{ 1 { 2 } }":                 true,
+		"AuraEffect { Aura 3 }":       true,
+		"":                            false,
+		"two words":                   false,
+		"{ unbalanced":                false,
+		"AuraEffect":                  false,
+		"AuraEffect { Aura 3 } extra": false,
+	} {
+		if err := text.RawValue(raw).Validate(); (err == nil) != valid {
+			t.Errorf("%q: got error %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package text
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// The registry of concrete types that can be stored in interface values
+var registry struct {
+	guard   sync.RWMutex
+	by_name map[string]reflect.Type
+	by_type map[reflect.Type]string
+}
+
+// Register records the type of value under name, so that values of that type can be
+// encoded and decoded in interface-typed fields. Such a value is written as its
+// type name followed by the value itself:
+//
+//	Effects
+//	{
+//		AuraEffect
+//		{
+//			Radius 5
+//		}
+//	}
+//
+// Registering a pointer (e.g. &AuraEffect{}) stores pointers in interfaces when decoding.
+// Values in an empty interface (any) only need to be registered if they are to be decoded
+// as their own type rather than as generic values (see Table).
+// Register panics if the name or the type is already registered differently.
+func Register(name string, value any) {
+	if name == "" {
+		panic("text: cannot register a type with an empty name")
+	}
+
+	t := reflect.TypeOf(value)
+	if t == nil {
+		panic("text: cannot register nil")
+	}
+
+	registry.guard.Lock()
+	defer registry.guard.Unlock()
+
+	if registry.by_name == nil {
+		registry.by_name = make(map[string]reflect.Type)
+		registry.by_type = make(map[reflect.Type]string)
+	}
+
+	if registered, ok := registry.by_name[name]; ok && registered != t {
+		panic(fmt.Sprintf("text: registering duplicate types for %q: %s != %s", name, registered, t))
+	}
+
+	if registered, ok := registry.by_type[t]; ok && registered != name {
+		panic(fmt.Sprintf("text: registering duplicate names for %s: %q != %q", t, registered, name))
+	}
+
+	registry.by_name[name] = t
+	registry.by_type[t] = name
+}
+
+// IsRegistered reports whether a type is registered under name.
+func IsRegistered(name string) bool {
+	_, ok := registered_type(name)
+	return ok
+}
+
+// Returns the name a type was registered under
+func registered_name(t reflect.Type) (name string, err error) {
+	registry.guard.RLock()
+	name, ok := registry.by_type[t]
+	registry.guard.RUnlock()
+	if !ok {
+		err = fmt.Errorf("text: type %s is not registered", t)
+	}
+	return
+}
+
+// Returns the type registered under a name
+func registered_type(name string) (t reflect.Type, ok bool) {
+	registry.guard.RLock()
+	t, ok = registry.by_name[name]
+	registry.guard.RUnlock()
+	return
+}
+
+// Reports whether the value in a non-nil interface is written after its type name.
+// The values of empty interfaces are written on their own unless their type is registered.
+func is_typed_interface(value reflect.Value) bool {
+	if value.NumMethod() > 0 {
+		return true
+	}
+	_, err := registered_name(value.Elem().Type())
+	return err == nil
+}
+
+// Reports whether a value is a string that would be mistaken for a type name if written as a bare word
+func is_type_name_string(value reflect.Value) bool {
+	if value.Kind() != reflect.String {
+		return false
+	}
+	_, ok := registered_type(value.String())
+	return ok
+}
+
+// Writes the registered name of the concrete type in a non-nil interface value
+func (encoder *Encoder) encode_type_name(value reflect.Value) (err error) {
+	var name string
+	name, err = registered_name(value.Elem().Type())
+	if err != nil {
+		return
+	}
+	return encoder.encode_string(name)
+}
+
+// Decodes an interface value: the word nil, or a registered type name followed by a value of that type.
+// An empty interface may also hold a generic value, unless the value begins with a bare type name.
+func (decoder *Decoder) decode_interface(value reflect.Value, column bool) (err error) {
+	decode := decoder.decode_value
+	if column {
+		decode = decoder.decode_column
+	}
+
+	if value.NumMethod() == 0 {
+		var next_token token
+		next_token, err = decoder.peek_token()
+		if err != nil {
+			return
+		}
+
+		if next_token.Type != token_word || next_token.Quoted {
+			return decoder.decode_generic(value, column)
+		}
+		if _, ok := registered_type(string(next_token.Data)); !ok {
+			return decoder.decode_generic(value, column)
+		}
+	}
+
+	var name_token token
+	name_token, err = decoder.next_word()
+	if err != nil {
+		return
+	}
+
+	if name_token.is_nil() {
+		value.Set(reflect.Zero(value.Type()))
+		return
+	}
+
+	concrete_type, ok := registered_type(string(name_token.Data))
+	if !ok {
+		return fmt.Errorf("type name %q is not registered", name_token.Data)
+	}
+
+	if !concrete_type.AssignableTo(value.Type()) {
+		return fmt.Errorf("registered type %s does not implement %s", concrete_type, value.Type())
+	}
+
+	concrete := reflect.New(concrete_type).Elem()
+	if err = decode(concrete); err != nil {
+		return
+	}
+
+	value.Set(concrete)
+	return
+}
@@ -0,0 +1,143 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type spell_effect interface {
+	Apply() string
+}
+
+type aura_effect struct {
+	Aura   uint32
+	Radius float32
+}
+
+func (aura *aura_effect) Apply() string {
+	return "aura"
+}
+
+type damage_effect uint32
+
+func (damage damage_effect) Apply() string {
+	return "damage"
+}
+
+func init() {
+	text.Register("AuraEffect", &aura_effect{})
+	text.Register("DamageEffect", damage_effect(0))
+}
+
+type registry_spell struct {
+	ID      uint32
+	Primary spell_effect
+	Effects []spell_effect
+}
+
+var registry_spells = []registry_spell{
+	{
+		ID:      133,
+		Primary: damage_effect(14),
+		Effects: []spell_effect{
+			&aura_effect{Aura: 3, Radius: 8},
+			damage_effect(20),
+			nil,
+		},
+	},
+}
+
+func TestRegisteredInterfaces(t *testing.T) {
+	data, err := text.Marshal(&registry_spells[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+	ID 133
+	Primary DamageEffect 14
+	Effects
+	{
+		AuraEffect
+		{
+			Aura 3
+			Radius 8
+		}
+		DamageEffect 20
+		nil
+	}
+}
+`
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+
+	var decoded registry_spell
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(registry_spells[0], decoded) {
+		t.Fatal("got back incorrect spell", decoded)
+	}
+}
+
+func TestRegisteredInterfacesTable(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+	encoder.Tabular = true
+
+	if err := encoder.Encode(&registry_spells[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[ ID Primary Effects ]\n{ 133 DamageEffect 14 { AuraEffect { 3 8 } DamageEffect 20 nil } }\n"
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	var decoded registry_spell
+	if err := text.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(registry_spells[0], decoded) {
+		t.Fatal("got back incorrect spell", decoded)
+	}
+}
+
+type unregistered_effect struct{}
+
+func (unregistered_effect) Apply() string {
+	return ""
+}
+
+func TestUnregisteredInterface(t *testing.T) {
+	if _, err := text.Marshal(&registry_spell{Primary: unregistered_effect{}}); err == nil {
+		t.Fatal("encoding an unregistered type should fail")
+	}
+
+	var decoded registry_spell
+	err := text.Unmarshal([]byte("{ Primary Unknown 5 }"), &decoded)
+	var decode_error *text.DecodeError
+	if !errors.As(err, &decode_error) || decode_error.Path != "Primary" {
+		t.Fatal("expected a decode error at Primary, got", err)
+	}
+}
+
+func TestIsRegistered(t *testing.T) {
+	if !text.IsRegistered("AuraEffect") {
+		t.Fatal("AuraEffect should be registered")
+	}
+	if text.IsRegistered("aura_effect") || text.IsRegistered("") {
+		t.Fatal("only registered names should be reported")
+	}
+}
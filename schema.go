@@ -0,0 +1,217 @@
+package text
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Typed table headers give the type of each column after its name, as in
+//
+//	[ ID:u32 Name:string Flags:[]u8 Position:{f32,f32,f32} ]
+//
+// Types are written as:
+//
+//	bool string                    booleans and strings
+//	i8 i16 i32 i64 u8 u16 u32 u64  integers (int and uint are i64 and u64)
+//	f32 f64                        floating point numbers
+//	word block raw any             Words, Marshalers, RawValues and interfaces
+//	*T []T [N]T map[K]V            pointers, slices, arrays and maps
+//	{T,U,...}                      structs, with the types of their fields in order
+var basic_type_codes = map[reflect.Kind]string{
+	reflect.Bool:    "bool",
+	reflect.String:  "string",
+	reflect.Int:     "i64",
+	reflect.Int8:    "i8",
+	reflect.Int16:   "i16",
+	reflect.Int32:   "i32",
+	reflect.Int64:   "i64",
+	reflect.Uint:    "u64",
+	reflect.Uint8:   "u8",
+	reflect.Uint16:  "u16",
+	reflect.Uint32:  "u32",
+	reflect.Uint64:  "u64",
+	reflect.Uintptr: "u64",
+	reflect.Float32: "f32",
+	reflect.Float64: "f64",
+}
+
+// Returns the type of a column that holds values of type t
+func type_code(t reflect.Type) string {
+	var builder strings.Builder
+	write_type_code(&builder, t, nil)
+	return builder.String()
+}
+
+// Writes the type code of t. Structs that contain themselves are written as {...} where they recur.
+func write_type_code(builder *strings.Builder, t reflect.Type, visiting []reflect.Type) {
+	if t == raw_value_type {
+		builder.WriteString("raw")
+		return
+	}
+
+	info := get_type_info(t)
+	switch {
+	case info.marshaler || info.unmarshaler:
+		builder.WriteString("block")
+		return
+	case info.word:
+		builder.WriteString("word")
+		return
+	}
+
+	if code, ok := basic_type_codes[t.Kind()]; ok {
+		builder.WriteString(code)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		builder.WriteString("*")
+		write_type_code(builder, t.Elem(), visiting)
+	case reflect.Slice:
+		builder.WriteString("[]")
+		write_type_code(builder, t.Elem(), visiting)
+	case reflect.Array:
+		builder.WriteString("[" + strconv.Itoa(t.Len()) + "]")
+		write_type_code(builder, t.Elem(), visiting)
+	case reflect.Map:
+		builder.WriteString("map[")
+		write_type_code(builder, t.Key(), visiting)
+		builder.WriteString("]")
+		write_type_code(builder, t.Elem(), visiting)
+	case reflect.Struct:
+		for _, v := range visiting {
+			if v == t {
+				builder.WriteString("{...}")
+				return
+			}
+		}
+		visiting = append(visiting, t)
+
+		builder.WriteString("{")
+		// Invalid struct tags are reported when the struct is encoded or decoded
+		fields, _ := get_struct_fields(t)
+		for i := range fields.list {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			write_type_code(builder, t.FieldByIndex(fields.list[i].index).Type, visiting)
+		}
+		builder.WriteString("}")
+	default:
+		builder.WriteString("any")
+	}
+}
+
+// Reports whether code is a complete type code
+func is_type_code(code string) bool {
+	rest, ok := parse_type_code(code)
+	return ok && rest == ""
+}
+
+// Parses the type code at the start of code, returning what follows it
+func parse_type_code(code string) (rest string, ok bool) {
+	for _, name := range []string{"bool", "string", "i8", "i16", "i32", "i64", "u8", "u16", "u32", "u64", "f32", "f64", "word", "block", "raw", "any"} {
+		if rest, ok = strings.CutPrefix(code, name); ok {
+			return
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(code, "*"):
+		return parse_type_code(code[1:])
+	case strings.HasPrefix(code, "[]"):
+		return parse_type_code(code[2:])
+	case strings.HasPrefix(code, "["):
+		end := strings.IndexByte(code, ']')
+		if end < 0 {
+			return
+		}
+		if _, err := strconv.Atoi(code[1:end]); err != nil {
+			return
+		}
+		return parse_type_code(code[end+1:])
+	case strings.HasPrefix(code, "map["):
+		if rest, ok = parse_type_code(code[4:]); !ok {
+			return
+		}
+		if rest, ok = strings.CutPrefix(rest, "]"); !ok {
+			return
+		}
+		return parse_type_code(rest)
+	case strings.HasPrefix(code, "{...}"):
+		return code[5:], true
+	case strings.HasPrefix(code, "{}"):
+		return code[2:], true
+	case strings.HasPrefix(code, "{"):
+		rest = code[1:]
+		for {
+			if rest, ok = parse_type_code(rest); !ok {
+				return
+			}
+			if strings.HasPrefix(rest, "}") {
+				return rest[1:], true
+			}
+			if rest, ok = strings.CutPrefix(rest, ","); !ok {
+				return
+			}
+		}
+	}
+	return "", false
+}
+
+// Splits a column of a table header into its name and type. If the column has no type, column_type is empty.
+func split_typed_column(column string) (name, column_type string) {
+	if i := strings.LastIndexByte(column, ':'); i > 0 && is_type_code(column[i+1:]) {
+		return column[:i], column[i+1:]
+	}
+	return column, ""
+}
+
+// Writes a column of a table header, with its type if typed is set
+func (encoder *Encoder) encode_header_column(name string, t reflect.Type, typed bool) (err error) {
+	if !typed {
+		return encoder.encode_string(name)
+	}
+
+	column := name + ":" + type_code(t)
+	// Brackets are only special at the start of a word, so the type can be left bare
+	if is_bare_word(name) {
+		_, err = encoder.out.WriteString(column)
+		return
+	}
+	return encoder.encode_quoted(column)
+}
+
+// Returns a *ColumnTypeError if a column's type in the table header does not match its field
+func check_column_type(row_type reflect.Type, f *field, column, column_type string) error {
+	if column_type == "" {
+		return nil
+	}
+
+	field_type := type_code(row_type.FieldByIndex(f.index).Type)
+	if field_type != column_type {
+		return &ColumnTypeError{
+			Column:     column,
+			HeaderType: column_type,
+			FieldType:  field_type,
+		}
+	}
+	return nil
+}
+
+// A ColumnTypeError reports that the type of a column in a typed table header
+// does not match the type of the field that the column is decoded into.
+type ColumnTypeError struct {
+	Column string
+	// The type given in the table header
+	HeaderType string
+	// The type of the field, in the same notation
+	FieldType string
+}
+
+func (e *ColumnTypeError) Error() string {
+	return fmt.Sprintf("text: column %s has type %s in the table header, but its field has type %s", e.Column, e.HeaderType, e.FieldType)
+}
@@ -0,0 +1,99 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type typed_position struct {
+	X, Y, Z float32
+}
+
+type typed_creature struct {
+	ID       uint32
+	Name     string
+	Flags    []uint8
+	Position typed_position
+	Display  *int64
+	Address  netip.Addr
+	Loot     map[string]uint16 `text:"Loot Table"`
+}
+
+type typed_creature_drifted struct {
+	ID    uint8
+	Name  string
+	Flags []uint8
+}
+
+func TestTypedHeader(t *testing.T) {
+	display := int64(-5)
+	creatures := []typed_creature{
+		{
+			ID:       1,
+			Name:     "Hogger",
+			Flags:    []uint8{1, 2},
+			Position: typed_position{1, 2.5, 3},
+			Display:  &display,
+			Address:  netip.MustParseAddr("127.0.0.1"),
+			Loot:     map[string]uint16{"Gold": 10},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := text.EncodeAll(&buf, creatures, text.EncodeOptions{Tabular: true, TypedHeader: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[ ID:u32 Name:string Flags:[]u8 Position:{f32,f32,f32} Display:*i64 Address:word "Loot Table:map[string]u16" ]
+{ 1 Hogger { 1 2 } { 1 2.5 3 } -5 127.0.0.1 { Gold 10 } }
+`
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	decoder := text.NewDecoder(bytes.NewReader(buf.Bytes()))
+	var decoded typed_creature
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, creatures[0]) {
+		t.Fatal("got back incorrect row", decoded)
+	}
+	if columns := decoder.Columns(); columns[6] != "Loot Table" {
+		t.Fatal("wrong columns", columns)
+	}
+	if types := decoder.ColumnTypes(); types[0] != "u32" || types[2] != "[]u8" {
+		t.Fatal("wrong column types", types)
+	}
+
+	// A type that no longer matches the table is reported before any row is decoded
+	decoder = text.NewDecoder(bytes.NewReader(buf.Bytes()))
+	decoder.SkipUnknownFields = true
+	var drifted typed_creature_drifted
+	err := decoder.Decode(&drifted)
+	var type_error *text.ColumnTypeError
+	if !errors.As(err, &type_error) {
+		t.Fatal("expected a column type error, got", err)
+	}
+	if type_error.Column != "ID" || type_error.HeaderType != "u32" || type_error.FieldType != "u8" {
+		t.Fatal("wrong column type error", type_error)
+	}
+
+	// Columns whose names merely contain a colon have no type
+	input := "[ ID:u32 Name:unknown ]\n{ 1 Hogger }\n"
+	decoder = text.NewDecoder(strings.NewReader(input))
+	decoder.SkipUnknownFields = true
+	var creature typed_creature
+	if err = decoder.Decode(&creature); err != nil {
+		t.Fatal(err)
+	}
+	if columns := decoder.Columns(); !reflect.DeepEqual(columns, []string{"ID", "Name:unknown"}) {
+		t.Fatal("wrong columns", columns)
+	}
+}
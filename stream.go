@@ -0,0 +1,103 @@
+package text
+
+import (
+	"errors"
+	"io"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind uint8
+
+const (
+	// The '{' that opens a block
+	TokenOpen = TokenKind(token_open)
+	// The '}' that closes a block
+	TokenClose = TokenKind(token_close)
+	// A bare or quoted word
+	TokenWord = TokenKind(token_word)
+	// The '[' that opens a table header
+	TokenTableHeaderOpen = TokenKind(token_open_table_header)
+	// The ']' that closes a table header
+	TokenTableHeaderClose = TokenKind(token_close_table_header)
+	// A double-slash or block comment. Only returned if Decoder.EmitComments is set.
+	TokenComment = TokenKind(token_comment)
+)
+
+func (kind TokenKind) String() string {
+	switch kind {
+	case TokenOpen:
+		return "Open"
+	case TokenClose:
+		return "Close"
+	case TokenWord:
+		return "Word"
+	case TokenTableHeaderOpen:
+		return "TableHeaderOpen"
+	case TokenTableHeaderClose:
+		return "TableHeaderClose"
+	case TokenComment:
+		return "Comment"
+	default:
+		return token_type(kind).String()
+	}
+}
+
+// A Token is a lexical element of text.
+type Token struct {
+	Kind TokenKind
+	// For words, the text of the word with quotes removed and escape sequences resolved.
+	// For comments, the comment including its delimiters, excluding the newline that ends a double-slash comment.
+	Data string
+	// True if the word was enclosed in quotes
+	Quoted bool
+	// Where the token begins. Line and Column count from 1, and columns count runes.
+	Line   int
+	Column int
+	Offset int64
+	// The byte offset just past the end of the token
+	End int64
+}
+
+func (t *token) export() Token {
+	return Token{
+		Kind:   TokenKind(t.Type),
+		Data:   string(t.Data),
+		Quoted: t.Quoted,
+		Line:   t.pos.line,
+		Column: t.pos.column,
+		Offset: t.pos.offset,
+		End:    t.end,
+	}
+}
+
+// The public API returns io.EOF itself at the end of input
+func unwrap_eof(err error) error {
+	var syntax_error *SyntaxError
+	if errors.Is(err, io.EOF) && !errors.As(err, &syntax_error) {
+		return io.EOF
+	}
+	return err
+}
+
+// Token returns the next token in the input stream.
+// At the end of the input, Token returns io.EOF.
+//
+// Token can be mixed freely with Decode, which makes it possible to inspect a stream
+// (for example, to choose a Go type for a record) before decoding parts of it.
+func (decoder *Decoder) Token() (Token, error) {
+	t, err := decoder.next(decoder.EmitComments)
+	if err != nil {
+		return Token{}, unwrap_eof(err)
+	}
+	return t.export(), nil
+}
+
+// PeekToken returns the next token in the input stream without consuming it.
+// At the end of the input, PeekToken returns io.EOF.
+func (decoder *Decoder) PeekToken() (Token, error) {
+	t, err := decoder.peek(decoder.EmitComments)
+	if err != nil {
+		return Token{}, unwrap_eof(err)
+	}
+	return t.export(), nil
+}
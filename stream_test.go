@@ -0,0 +1,80 @@
+package text_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+func TestToken(t *testing.T) {
+	input := "[ ID \"Display Name\" ]\n// first row\n{ 1 /* name */ \"Hog\\\"ger\" }\n"
+
+	decoder := text.NewDecoder(strings.NewReader(input))
+	decoder.EmitComments = true
+
+	expected := []text.Token{
+		{Kind: text.TokenTableHeaderOpen, Line: 1, Column: 1, Offset: 0, End: 1},
+		{Kind: text.TokenWord, Data: "ID", Line: 1, Column: 3, Offset: 2, End: 4},
+		{Kind: text.TokenWord, Data: "Display Name", Quoted: true, Line: 1, Column: 6, Offset: 5, End: 19},
+		{Kind: text.TokenTableHeaderClose, Line: 1, Column: 21, Offset: 20, End: 21},
+		{Kind: text.TokenComment, Data: "// first row", Line: 2, Column: 1, Offset: 22, End: 34},
+		{Kind: text.TokenOpen, Line: 3, Column: 1, Offset: 35, End: 36},
+		{Kind: text.TokenWord, Data: "1", Line: 3, Column: 3, Offset: 37, End: 38},
+		{Kind: text.TokenComment, Data: "/* name */", Line: 3, Column: 5, Offset: 39, End: 49},
+		{Kind: text.TokenWord, Data: "Hog\"ger", Quoted: true, Line: 3, Column: 16, Offset: 50, End: 60},
+		{Kind: text.TokenClose, Line: 3, Column: 27, Offset: 61, End: 62},
+	}
+
+	for i, e := range expected {
+		peeked, err := decoder.PeekToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := decoder.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(peeked, token) || !reflect.DeepEqual(token, e) {
+			t.Fatalf("token %d: got %+v, expected %+v", i, token, e)
+		}
+
+		if string(input[token.Offset:token.End]) == "" {
+			t.Fatal("empty token source")
+		}
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		t.Fatal("expected io.EOF, got", err)
+	}
+}
+
+func TestTokenMixedWithDecode(t *testing.T) {
+	decoder := text.NewDecoder(strings.NewReader("Spell { ID 1 } Item { ID 2 }"))
+
+	for _, expected := range []string{"Spell", "Item"} {
+		kind, err := decoder.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if kind.Data != expected {
+			t.Fatal("expected", expected, "got", kind.Data)
+		}
+
+		var record struct{ ID int }
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var record struct{ ID int }
+	if err := decoder.Decode(&record); !errors.Is(err, io.EOF) {
+		t.Fatal("expected io.EOF, got", err)
+	}
+}
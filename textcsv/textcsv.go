@@ -0,0 +1,222 @@
+// Package textcsv converts tabular text documents to CSV or TSV files and back, so that tables can
+// be edited in a spreadsheet.
+//
+// The header row of the file holds the table's columns, including their types if the table header
+// is typed. Each other row holds a row of the table, with a cell for each column:
+//
+//	ID,Name,Ranks,Icon
+//	133,Fire Ball,{ 1 2 },nil
+//	116,,{},"""nil"""
+//
+// A string is written as it is, so the empty cell above is an empty string. A row whose cells
+// all hold empty strings is read as a zero row, which is written with an empty cell for each column. Blocks, such as
+// slices, maps and structs, are written in the braced syntax of the text format, nil is written
+// as nil, and a value in an interface is written after its registered type name. A string that
+// could be mistaken for one of those is written quoted as a text word.
+//
+// Cells keep the source text of each column, so converting a table to a CSV file and back gives
+// the same values, whatever their types.
+package textcsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Gophercraft/text"
+)
+
+// Options configure the format of CSV files.
+type Options struct {
+	// The character between the cells of a row. If zero, this is ','. Use '\t' for TSV files.
+	Comma rune
+}
+
+func (opts *Options) comma() rune {
+	if opts.Comma == 0 {
+		return ','
+	}
+	return opts.Comma
+}
+
+// Reports whether a string can be written in a cell as it is
+func is_plain(str string) bool {
+	return str != "nil" && !strings.HasPrefix(str, "{") && !strings.HasPrefix(str, `"`) && !is_typed_value(str)
+}
+
+// Reports whether a cell holds exactly one value of a registered type, written after its type name
+func is_typed_value(cell string) bool {
+	cell = strings.TrimLeft(cell, " \t\r\n")
+	end := strings.IndexAny(cell, " \t\r\n")
+	if end < 0 || !text.IsRegistered(cell[:end]) {
+		return false
+	}
+	return text.RawValue(cell).Validate() == nil
+}
+
+// Returns the cell that holds a column
+func cell(column text.RawValue) (str string, err error) {
+	if len(column) > 0 && column[0] == '"' {
+		if err = text.Unmarshal(column, &str); err != nil {
+			return
+		}
+		if is_plain(str) {
+			return
+		}
+	}
+	return string(column), nil
+}
+
+// Returns the column held by a cell, as a string or a RawValue
+func column(cell string) any {
+	if is_plain(cell) {
+		return cell
+	}
+	return text.RawValue(cell)
+}
+
+// Reports whether every cell of a record holds an empty string, as the cells of a zero row do
+func is_zero_record(record []string) bool {
+	for _, cell := range record {
+		if cell != "" && cell != `""` {
+			return false
+		}
+	}
+	return true
+}
+
+// Export converts the table in the text document read from r to a CSV file written to w.
+// The document must hold exactly one table.
+func Export(w io.Writer, r io.Reader, opts Options) (err error) {
+	decoder := text.NewDecoder(r)
+
+	var t text.Token
+	if t, err = decoder.PeekToken(); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("textcsv: the document is empty")
+		}
+		return
+	}
+	if t.Kind != text.TokenTableHeaderOpen {
+		return fmt.Errorf("textcsv: the document is not a table")
+	}
+	if err = decoder.NextTable(); err != nil {
+		return
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma()
+
+	header := decoder.Columns()
+	for i, column_type := range decoder.ColumnTypes() {
+		if column_type != "" {
+			header[i] += ":" + column_type
+		}
+	}
+	if err = writer.Write(header); err != nil {
+		return
+	}
+
+	for decoder.More() {
+		var row []text.RawValue
+		if err = decoder.Decode(&row); err != nil {
+			return
+		}
+
+		// A zero row has no cells, so it is given an empty cell for each column
+		if len(row) != 0 && len(row) != len(header) {
+			return fmt.Errorf("textcsv: row has %d values, but the table has %d columns", len(row), len(header))
+		}
+		record := make([]string, len(header))
+		for i := range row {
+			if record[i], err = cell(row[i]); err != nil {
+				return
+			}
+		}
+		// A lone empty cell would be an empty line, which is skipped when the file is read
+		if len(record) == 1 && record[0] == "" {
+			record[0] = `""`
+		}
+		if err = writer.Write(record); err != nil {
+			return
+		}
+	}
+
+	if err = decoder.NextTable(); err == nil {
+		return fmt.Errorf("textcsv: the document has more than one table")
+	} else if !errors.Is(err, io.EOF) {
+		return
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Import converts the CSV file read from r to a table in a text document written to w.
+// The first row of the file holds the names of the columns.
+func Import(w io.Writer, r io.Reader, opts Options) (err error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.comma()
+
+	var header []string
+	if header, err = reader.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("textcsv: the file has no header row")
+		}
+		return
+	}
+
+	encoder := text.NewEncoder(w)
+	encoder.Indent = " "
+	if err = encoder.BeginGenericTable(header); err != nil {
+		return
+	}
+
+	for {
+		var record []string
+		if record, err = reader.Read(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return
+		}
+
+		var row []any
+		if !is_zero_record(record) {
+			row = make([]any, len(record))
+			for i := range record {
+				row[i] = column(record[i])
+			}
+		}
+		if err = encoder.Encode(row); err != nil {
+			line, _ := reader.FieldPos(0)
+			return fmt.Errorf("textcsv: line %d: %w", line, err)
+		}
+	}
+
+	if err = encoder.EndTable(); err != nil {
+		return
+	}
+	return encoder.Flush()
+}
+
+// EncodeAll writes rows to w as a CSV file, with a column for each field of T.
+func EncodeAll[T any](w io.Writer, rows []T, opts Options) (err error) {
+	var document bytes.Buffer
+	if err = text.EncodeAll(&document, rows, text.EncodeOptions{Tabular: true}); err != nil {
+		return
+	}
+	return Export(w, &document, opts)
+}
+
+// DecodeAll reads the rows of the CSV file in r into a slice of T.
+func DecodeAll[T any](r io.Reader, opts Options) (rows []T, err error) {
+	var document bytes.Buffer
+	if err = Import(&document, r, opts); err != nil {
+		return
+	}
+	return text.DecodeAll[T](&document)
+}
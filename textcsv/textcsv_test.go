@@ -0,0 +1,226 @@
+package textcsv_test
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gophercraft/text"
+	"github.com/Gophercraft/text/textcsv"
+)
+
+type csv_effect interface {
+	Apply() string
+}
+
+type csv_aura struct {
+	Aura   uint32
+	Radius float32
+}
+
+func (aura *csv_aura) Apply() string {
+	return "aura"
+}
+
+func init() {
+	text.Register("Aura", &csv_aura{})
+}
+
+type csv_position struct {
+	X, Y, Z float32
+}
+
+type csv_spell struct {
+	ID       uint32
+	Name     string
+	Rank     int8
+	Scale    float64
+	Passive  bool
+	Ranks    []uint16
+	Costs    map[string]int32
+	Position csv_position
+	Color    [3]uint8
+	Icon     *string
+	Effect   csv_effect
+	Server   netip.Addr
+	Added    time.Time
+	Script   text.RawValue
+}
+
+var csv_icon = "fire,ball"
+
+var csv_spells = []csv_spell{
+	{
+		ID:       133,
+		Name:     "Fire Ball",
+		Rank:     -1,
+		Scale:    0.25,
+		Passive:  true,
+		Ranks:    []uint16{1, 2},
+		Costs:    map[string]int32{"Mana": 30, "Rage": -5},
+		Position: csv_position{1, 2.5, -3},
+		Color:    [3]uint8{255, 64, 0},
+		Icon:     &csv_icon,
+		Effect:   &csv_aura{Aura: 3, Radius: 8},
+		Server:   netip.MustParseAddr("10.0.0.1"),
+		Added:    time.Date(2004, 11, 23, 0, 0, 0, 0, time.UTC),
+		Script:   text.RawValue("{ Cast { Target self } }"),
+	},
+	{
+		ID:     116,
+		Name:   "nil",
+		Effect: &csv_aura{},
+		Script: text.RawValue("nil"),
+	},
+	{
+		ID:     118,
+		Name:   "{ \"Polymorph\"\n}",
+		Ranks:  []uint16{},
+		Script: text.RawValue("cast"),
+	},
+	{
+		ID:     120,
+		Name:   "",
+		Script: text.RawValue(`"quoted, with a comma"`),
+	},
+	// Strings that begin with a type name, with and without a single value after it
+	{
+		ID:     121,
+		Name:   "Aura of doom",
+		Script: text.RawValue("nil"),
+	},
+	{
+		ID:     122,
+		Name:   "Aura { 1 2 }",
+		Script: text.RawValue("nil"),
+	},
+	// A zero row, which has no cells in the document
+	{},
+}
+
+func TestRoundTrip(t *testing.T) {
+	// The values a table holds, such as empty slices where they were nil
+	var document strings.Builder
+	if err := text.EncodeAll(&document, csv_spells, text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := text.DecodeAll[csv_spell](strings.NewReader(document.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, comma := range []rune{0, '\t'} {
+		opts := textcsv.Options{Comma: comma}
+
+		var file strings.Builder
+		if err := textcsv.EncodeAll(&file, csv_spells, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := textcsv.DecodeAll[csv_spell](strings.NewReader(file.String()), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Fatalf("got back incorrect rows %+v\nfrom\n%s", rows, file.String())
+		}
+
+		// The document is written back as it was
+		var back strings.Builder
+		if err = textcsv.Import(&back, strings.NewReader(file.String()), opts); err != nil {
+			t.Fatal(err)
+		}
+		if back.String() != document.String() {
+			t.Fatal(back.String(), "should have been equal to", document.String())
+		}
+	}
+}
+
+type csv_id struct {
+	ID uint32
+}
+
+type csv_name struct {
+	Name string
+}
+
+func TestZeroRows(t *testing.T) {
+	// Rows of one empty cell are not lost as empty lines
+	ids := []csv_id{{}, {1}, {}}
+	var file strings.Builder
+	if err := textcsv.EncodeAll(&file, ids, textcsv.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if file.String() != "ID\n\"\"\"\"\"\"\n1\n\"\"\"\"\"\"\n" {
+		t.Fatalf("wrong file\n%s", file.String())
+	}
+	decoded_ids, err := textcsv.DecodeAll[csv_id](strings.NewReader(file.String()), textcsv.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded_ids, ids) {
+		t.Fatalf("got back incorrect rows %+v", decoded_ids)
+	}
+
+	names := []csv_name{{"Thrall"}, {""}}
+	file.Reset()
+	if err = textcsv.EncodeAll(&file, names, textcsv.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	decoded_names, err := textcsv.DecodeAll[csv_name](strings.NewReader(file.String()), textcsv.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded_names, names) {
+		t.Fatalf("got back incorrect rows %+v", decoded_names)
+	}
+}
+
+func TestExport(t *testing.T) {
+	input := `[ ID:u32 Name:string Ranks:[]u8 Icon:*string ]
+{ 133 "Fire Ball" { 1 2 } nil }
+{ 116 "" {} "nil" }
+{ 118 "{Sheep}" nil "\"Sheep\"" }
+`
+
+	var file strings.Builder
+	if err := textcsv.Export(&file, strings.NewReader(input), textcsv.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `ID:u32,Name:string,Ranks:[]u8,Icon:*string
+133,Fire Ball,{ 1 2 },nil
+116,,{},"""nil"""
+118,"""{Sheep}""",nil,"""\""Sheep\"""""
+`
+	if file.String() != expected {
+		t.Fatal(file.String(), "should have been equal to", expected)
+	}
+
+	var document strings.Builder
+	if err := textcsv.Import(&document, strings.NewReader(file.String()), textcsv.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if document.String() != input {
+		t.Fatal(document.String(), "should have been equal to", input)
+	}
+
+	if err := textcsv.Export(&file, strings.NewReader(`{ ID 133 }`), textcsv.Options{}); err == nil {
+		t.Fatal("expected an error for a document that is not a table")
+	}
+	if err := textcsv.Export(&file, strings.NewReader(input+input), textcsv.Options{}); err == nil {
+		t.Fatal("expected an error for a document with two tables")
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	var document strings.Builder
+	if err := textcsv.Import(&document, strings.NewReader("ID,Ranks\n133,{ 1 2\n"), textcsv.Options{}); err == nil {
+		t.Fatal("expected an error for an unclosed block")
+	}
+	if err := textcsv.Import(&document, strings.NewReader("ID,Name\n133\n"), textcsv.Options{}); err == nil {
+		t.Fatal("expected an error for a missing cell")
+	}
+}
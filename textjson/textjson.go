@@ -0,0 +1,391 @@
+// Package textjson converts text documents to JSON and back.
+//
+// Each top-level value of a text document becomes a JSON value, and each table becomes a JSON
+// array with an object for each row. Conversions read and write one value or row at a time, so
+// tables do not have to fit in memory.
+//
+// Without a Go type, words become JSON strings, keyed blocks become objects, and unkeyed blocks
+// become arrays. In the other direction, JSON numbers, strings and booleans all become words,
+// written with the same quoting rules as the Encoder, and null becomes nil.
+//
+// With a Go type, each value or row is decoded into the type from one format and encoded into the
+// other, using the type's text and json struct tags.
+package textjson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/Gophercraft/text"
+)
+
+// ToJSON converts the text document in r to JSON written to w, without a Go type.
+func ToJSON(w io.Writer, r io.Reader) error {
+	return to_json(w, r,
+		func(decoder *text.Decoder) (value any, err error) {
+			err = decoder.Decode(&value)
+			return
+		},
+		func(decoder *text.Decoder) (data []byte, err error) {
+			// A generic row decodes as a []any
+			var row any
+			if err = decoder.Decode(&row); err != nil {
+				return
+			}
+			return marshal_row(decoder.Columns(), row.([]any))
+		})
+}
+
+// ToJSONAs converts the text document in r to JSON written to w, decoding each value or row as a T.
+func ToJSONAs[T any](w io.Writer, r io.Reader) error {
+	return to_json(w, r,
+		func(decoder *text.Decoder) (value any, err error) {
+			var v T
+			err = decoder.Decode(&v)
+			return v, err
+		},
+		func(decoder *text.Decoder) (data []byte, err error) {
+			var row T
+			if err = decoder.Decode(&row); err != nil {
+				return
+			}
+			return marshal(row)
+		})
+}
+
+// Writes each value and table of a text document as JSON, one per line
+func to_json(w io.Writer, r io.Reader, decode_value func(*text.Decoder) (any, error), decode_row func(*text.Decoder) ([]byte, error)) (err error) {
+	var (
+		decoder = text.NewDecoder(r)
+		out     = bufio.NewWriter(w)
+	)
+
+	for {
+		var t text.Token
+		if t, err = decoder.PeekToken(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return
+		}
+
+		if t.Kind == text.TokenTableHeaderOpen {
+			if err = decoder.NextTable(); err != nil {
+				return
+			}
+
+			out.WriteString("[")
+			rows := 0
+			for ; decoder.More(); rows++ {
+				var data []byte
+				if data, err = decode_row(decoder); err != nil {
+					return
+				}
+				if rows > 0 {
+					out.WriteString(",")
+				}
+				out.WriteString("\n")
+				out.Write(data)
+			}
+			if rows > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString("]\n")
+			continue
+		}
+
+		var (
+			value any
+			data  []byte
+		)
+		if value, err = decode_value(decoder); err != nil {
+			return
+		}
+		if data, err = marshal(value); err != nil {
+			return
+		}
+		out.Write(data)
+		out.WriteString("\n")
+	}
+
+	return out.Flush()
+}
+
+// Marshals a value as JSON without escaping HTML characters
+func marshal(value any) (data []byte, err error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(value); err != nil {
+		return
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Marshals a row of generic values as an object, with its keys in the order of the columns
+func marshal_row(columns []string, row []any) (data []byte, err error) {
+	if len(row) > len(columns) {
+		return nil, fmt.Errorf("textjson: row has %d values, but the table has %d columns", len(row), len(columns))
+	}
+
+	data = []byte("{")
+	for i, value := range row {
+		if i > 0 {
+			data = append(data, ',')
+		}
+
+		var key, element []byte
+		if key, err = marshal(columns[i]); err != nil {
+			return
+		}
+		if element, err = marshal(value); err != nil {
+			return
+		}
+		data = append(data, key...)
+		data = append(data, ':')
+		data = append(data, element...)
+	}
+	return append(data, '}'), nil
+}
+
+// Returns an Encoder configured like EncodeAll
+func new_encoder(w io.Writer, opts text.EncodeOptions) *text.Encoder {
+	encoder := text.NewEncoder(w)
+	encoder.TypedHeader = opts.TypedHeader
+	switch {
+	case opts.Indent != "":
+		encoder.Indent = opts.Indent
+	case opts.Tabular:
+		encoder.Indent = " "
+	}
+	return encoder
+}
+
+// FromJSON converts the JSON values in r to a text document written to w, without a Go type.
+// If opts.Tabular is set, each top-level array must hold objects, and is written as a table whose
+// columns are the keys of its first object. Keys missing from later objects are written as nil.
+// A table continues until the next table header, so other values should come before the arrays.
+func FromJSON(w io.Writer, r io.Reader, opts text.EncodeOptions) (err error) {
+	var (
+		decoder = json.NewDecoder(r)
+		encoder = new_encoder(w, opts)
+	)
+	decoder.UseNumber()
+
+	for {
+		var t json.Token
+		if t, err = decoder.Token(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return
+		}
+
+		if opts.Tabular && t == json.Delim('[') {
+			if err = write_table(decoder, encoder); err != nil {
+				return
+			}
+			continue
+		}
+
+		var value any
+		if value, err = read_value(decoder, t); err != nil {
+			return
+		}
+		if err = encoder.Encode(&value); err != nil {
+			return
+		}
+	}
+
+	return encoder.Flush()
+}
+
+// FromJSONAs converts the JSON values in r to a text document written to w, decoding each one as a T.
+// If opts.Tabular is set, each top-level value must be an array, which is written as a table of T.
+func FromJSONAs[T any](w io.Writer, r io.Reader, opts text.EncodeOptions) (err error) {
+	var (
+		decoder = json.NewDecoder(r)
+		encoder = new_encoder(w, opts)
+	)
+
+	for {
+		if !opts.Tabular {
+			var value T
+			if err = decoder.Decode(&value); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return
+			}
+			if err = encoder.Encode(&value); err != nil {
+				return
+			}
+			continue
+		}
+
+		var t json.Token
+		if t, err = decoder.Token(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return
+		}
+		if t != json.Delim('[') {
+			return fmt.Errorf("textjson: expected an array of rows, found %v", t)
+		}
+
+		if err = encoder.BeginTable(reflect.TypeFor[T]()); err != nil {
+			return
+		}
+		for decoder.More() {
+			var row T
+			if err = decoder.Decode(&row); err != nil {
+				return
+			}
+			if err = encoder.Encode(&row); err != nil {
+				return
+			}
+		}
+		if _, err = decoder.Token(); err != nil {
+			return
+		}
+		if err = encoder.EndTable(); err != nil {
+			return
+		}
+	}
+
+	return encoder.Flush()
+}
+
+// Writes the objects of a JSON array as a table, once its opening bracket has been read
+func write_table(decoder *json.Decoder, encoder *text.Encoder) (err error) {
+	var columns []string
+
+	for rows := 0; decoder.More(); rows++ {
+		var t json.Token
+		if t, err = decoder.Token(); err != nil {
+			return
+		}
+		if t != json.Delim('{') {
+			return fmt.Errorf("textjson: a table row must be an object, found %v", t)
+		}
+
+		var (
+			keys   []string
+			values []any
+		)
+		if keys, values, err = read_object(decoder); err != nil {
+			return
+		}
+
+		if rows == 0 {
+			columns = keys
+			if err = encoder.BeginGenericTable(columns); err != nil {
+				return
+			}
+		}
+
+		row := make([]any, len(columns))
+		for i, key := range keys {
+			column := slices.Index(columns, key)
+			if column < 0 {
+				return fmt.Errorf("textjson: key %q in row %d is not a column of the table", key, rows)
+			}
+			row[column] = values[i]
+		}
+
+		if err = encoder.Encode(row); err != nil {
+			return
+		}
+	}
+
+	// The closing bracket
+	if _, err = decoder.Token(); err != nil {
+		return
+	}
+
+	// An empty array has no columns
+	if columns == nil {
+		if err = encoder.BeginGenericTable(nil); err != nil {
+			return
+		}
+	}
+	return encoder.EndTable()
+}
+
+// Reads the keys and values of a JSON object in order, once its opening brace has been read
+func read_object(decoder *json.Decoder) (keys []string, values []any, err error) {
+	for decoder.More() {
+		var t json.Token
+		if t, err = decoder.Token(); err != nil {
+			return
+		}
+		key, _ := t.(string)
+
+		if t, err = decoder.Token(); err != nil {
+			return
+		}
+		var value any
+		if value, err = read_value(decoder, t); err != nil {
+			return
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	// The closing brace
+	_, err = decoder.Token()
+	return
+}
+
+// Reads a JSON value beginning with the token t, as a generic text value
+func read_value(decoder *json.Decoder, t json.Token) (value any, err error) {
+	switch v := t.(type) {
+	case json.Delim:
+		if v == json.Delim('{') {
+			var (
+				keys   []string
+				values []any
+			)
+			if keys, values, err = read_object(decoder); err != nil {
+				return
+			}
+			object := make(map[string]any, len(keys))
+			for i, key := range keys {
+				object[key] = values[i]
+			}
+			return object, nil
+		}
+
+		list := []any{}
+		for decoder.More() {
+			var element any
+			if t, err = decoder.Token(); err != nil {
+				return
+			}
+			if element, err = read_value(decoder, t); err != nil {
+				return
+			}
+			list = append(list, element)
+		}
+		// The closing bracket
+		_, err = decoder.Token()
+		return list, err
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return v, nil
+	default:
+		return nil, nil
+	}
+}
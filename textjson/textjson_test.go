@@ -0,0 +1,142 @@
+package textjson_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+	"github.com/Gophercraft/text/textjson"
+)
+
+func TestToJSON(t *testing.T) {
+	input := `{
+	Name "Fire Ball"
+	Ranks { 1 2 }
+	Icon nil
+}
+[ ID Name Tags ]
+{ 133 "Fire <Ball>" { fire projectile } }
+{ 116 Frostbolt nil }
+`
+
+	var out strings.Builder
+	if err := textjson.ToJSON(&out, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows keep the order of the columns
+	expected := `{"Icon":null,"Name":"Fire Ball","Ranks":["1","2"]}
+[
+{"ID":"133","Name":"Fire <Ball>","Tags":["fire","projectile"]},
+{"ID":"116","Name":"Frostbolt","Tags":null}
+]
+`
+	if out.String() != expected {
+		t.Fatal(out.String(), "should have been equal to", expected)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	input := `[
+	{"ID": 133, "Name": "Fire Ball", "Tags": ["fire", "projectile"]},
+	{"Name": "Frostbolt", "ID": 116, "Passive": false}
+]`
+
+	var out strings.Builder
+	if err := textjson.FromJSON(&out, strings.NewReader(input), text.EncodeOptions{Tabular: true}); err == nil {
+		t.Fatal("expected an error for a key that is not a column")
+	}
+
+	input = `{"Level": 1.5, "Passive": true, "Icon": null}
+[
+	{"ID": 133, "Name": "Fire Ball", "Tags": ["fire", "projectile"]},
+	{"Name": "Frostbolt", "ID": 116}
+]
+[]`
+
+	out.Reset()
+	if err := textjson.FromJSON(&out, strings.NewReader(input), text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+ Icon nil
+ Level 1.5
+ Passive true
+}
+[ ID Name Tags ]
+{ 133 "Fire Ball" { fire projectile } }
+{ 116 Frostbolt nil }
+[ ]
+`
+	if out.String() != expected {
+		t.Fatal(out.String(), "should have been equal to", expected)
+	}
+
+	// Converting back gives the same values, with every word as a string
+	var back strings.Builder
+	if err := textjson.ToJSON(&back, strings.NewReader(out.String())); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"Icon":null,"Level":"1.5","Passive":"true"}
+[
+{"ID":"133","Name":"Fire Ball","Tags":["fire","projectile"]},
+{"ID":"116","Name":"Frostbolt","Tags":null}
+]
+[]
+`
+	if back.String() != expected {
+		t.Fatal(back.String(), "should have been equal to", expected)
+	}
+}
+
+type json_spell struct {
+	ID      uint32   `json:"id"`
+	Name    string   `json:"name"`
+	Ranks   []uint16 `json:"ranks"`
+	Passive bool     `json:"passive"`
+	Scale   *float32 `json:"scale,omitempty"`
+}
+
+func TestJSONAs(t *testing.T) {
+	input := `[ ID Name Ranks Passive Scale ]
+{ 133 "Fire Ball" { 1 2 } false nil }
+{ 116 Frostbolt {} true 0.5 }
+`
+
+	var out strings.Builder
+	if err := textjson.ToJSONAs[json_spell](&out, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[
+{"id":133,"name":"Fire Ball","ranks":[1,2],"passive":false},
+{"id":116,"name":"Frostbolt","ranks":null,"passive":true,"scale":0.5}
+]
+`
+	if out.String() != expected {
+		t.Fatal(out.String(), "should have been equal to", expected)
+	}
+
+	var back strings.Builder
+	if err := textjson.FromJSONAs[json_spell](&back, strings.NewReader(out.String()), text.EncodeOptions{Tabular: true}); err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != input {
+		t.Fatal(back.String(), "should have been equal to", input)
+	}
+
+	if err := textjson.FromJSONAs[json_spell](&back, strings.NewReader(`{"id":1}`), text.EncodeOptions{Tabular: true}); err == nil {
+		t.Fatal("expected an error for a table that is not an array")
+	}
+
+	// Without Tabular, each JSON value is a document
+	back.Reset()
+	if err := textjson.FromJSONAs[json_spell](&back, strings.NewReader(`{"id":1,"name":"Wrath"}`), text.EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "{\n\tID 1\n\tName Wrath\n}\n"
+	if back.String() != expected {
+		t.Fatal(back.String(), "should have been equal to", expected)
+	}
+}
@@ -1,242 +1,484 @@
-package text
-
-import (
-	"errors"
-	"fmt"
-	"io"
-)
-
-type token_type uint8
-
-const (
-	token_open token_type = iota
-	token_close
-	token_word
-	token_open_table_header
-	token_close_table_header
-)
-
-type token struct {
-	Type token_type
-	Data string
-}
-
-func (decoder *Decoder) read_quoted_word() (word *token, err error) {
-	word = &token{token_word, ""}
-	_, err = decoder.input.ReadByte()
-	if err != nil {
-		return
-	}
-
-	for {
-		var next_char rune
-		next_char, _, err = decoder.input.ReadRune()
-		if err != nil {
-			return
-		}
-
-		if next_char == '"' {
-			return
-		}
-
-		if next_char == '\n' {
-			decoder.line++
-			decoder.column = 0
-		}
-
-		if next_char == '\\' {
-			var escaped_char rune
-			escaped_char, _, err = decoder.input.ReadRune()
-			if err != nil {
-				return
-			}
-
-			switch escaped_char {
-			case 'n':
-				next_char = '\n'
-			case 'r':
-				next_char = '\r'
-			case 't':
-				next_char = '\t'
-			case '\\':
-				next_char = '\\'
-			case '"':
-				next_char = '"'
-			default:
-				return nil, fmt.Errorf("unknown escape sequence: \\%c", next_char)
-			}
-		}
-
-		decoder.column++
-
-		word.Data += string(next_char)
-	}
-}
-
-func (decoder *Decoder) read_word() (word *token, err error) {
-	var beginning []byte
-	beginning, err = decoder.input.Peek(1)
-	if err != nil {
-		err = fmt.Errorf("error peeking in read_word: %w", err)
-		return
-	}
-
-	if beginning[0] == '"' {
-		return decoder.read_quoted_word()
-	}
-
-	word = &token{token_word, ""}
-
-	for {
-		var next_char rune
-		next_char, _, err = decoder.input.ReadRune()
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				err = nil
-				return
-			}
-			err = fmt.Errorf("error while reading next character: %w", err)
-			return
-		}
-
-		// whitespace or new line can terminate a word
-		switch next_char {
-		case ' ':
-			decoder.column++
-			return
-		case '\n':
-			decoder.column = 1
-			decoder.line++
-			return
-		case '\r':
-			return
-		case '\t':
-			decoder.column++
-			return
-		}
-
-		word.Data += string(next_char)
-		decoder.column++
-	}
-}
-
-// Read a token from the input stream while not consuming it
-func (decoder *Decoder) peek_token() (t *token, err error) {
-	t, err = decoder.next_token()
-	if err != nil {
-		return
-	}
-
-	decoder.peeked_tokens = append(decoder.peeked_tokens, t)
-	return
-}
-
-// Consume a token
-func (decoder *Decoder) next_token() (t *token, err error) {
-	if len(decoder.peeked_tokens) > 0 {
-		t = decoder.peeked_tokens[0]
-		decoder.peeked_tokens = decoder.peeked_tokens[1:]
-		return
-	}
-
-	var b []byte
-
-main_loop:
-	for {
-		b, err = decoder.input.Peek(1)
-		if err != nil {
-			err = fmt.Errorf("error peeking in input: %w", err)
-			return
-		}
-
-		switch b[0] {
-		case '/':
-			var ss []byte
-			ss, err = decoder.input.Peek(2)
-			if err != nil {
-				return
-			}
-			// Read double-slash comment
-			if ss[1] == '/' {
-				if _, err = decoder.input.ReadString('\n'); err != nil {
-					return
-				}
-				continue main_loop
-			} else if ss[1] == '*' {
-				// Read a block comment
-				var d [2]byte
-				decoder.input.Read(d[:])
-
-				for {
-					var r rune
-					r, _, err = decoder.input.ReadRune()
-					if err != nil {
-						return
-					}
-
-					if r == '*' {
-						r, _, err = decoder.input.ReadRune()
-						if err != nil {
-							return
-						}
-
-						if r == '/' {
-							continue main_loop
-						}
-					}
-				}
-			} else {
-				return nil, fmt.Errorf("stray comment")
-			}
-		// whitespace
-		case ' ', '\t':
-			decoder.input.ReadByte()
-			decoder.column++
-			continue
-		case '\r':
-			decoder.input.ReadByte()
-			decoder.column++
-			continue
-		case '\n':
-			decoder.input.ReadByte()
-			decoder.line++
-			decoder.column = 1
-		case '[':
-			decoder.input.ReadByte()
-			decoder.column++
-			t = &token{Type: token_open_table_header}
-			return
-		case ']':
-			decoder.input.ReadByte()
-			decoder.column++
-			t = &token{Type: token_close_table_header}
-			return
-		case '{':
-			decoder.input.ReadByte()
-			decoder.column++
-			t = &token{Type: token_open}
-			return
-		case '}':
-			decoder.input.ReadByte()
-			decoder.column++
-			t = &token{Type: token_close}
-			return
-		default:
-			t, err = decoder.read_word()
-			return
-		}
-	}
-}
-
-func (decoder *Decoder) next_word() (word *token, err error) {
-	word, err = decoder.next_token()
-	if err != nil {
-		return nil, err
-	}
-
-	if word.Type != token_word {
-		return nil, fmt.Errorf("invalid Token type %d", word.Type)
-	}
-
-	return
-}
+package text
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+type token_type uint8
+
+const (
+	token_open token_type = iota
+	token_close
+	token_word
+	token_open_table_header
+	token_close_table_header
+	token_comment
+)
+
+func (t token_type) String() string {
+	switch t {
+	case token_open:
+		return "'{'"
+	case token_close:
+		return "'}'"
+	case token_word:
+		return "word"
+	case token_open_table_header:
+		return "'['"
+	case token_close_table_header:
+		return "']'"
+	case token_comment:
+		return "comment"
+	default:
+		return fmt.Sprintf("token type %d", uint8(t))
+	}
+}
+
+type token struct {
+	Type token_type
+	// The text of a word or comment. Unless a quoted word contains escape sequences, this refers
+	// to the Decoder's buffer, and is only valid until the next token is scanned.
+	Data []byte
+	// True if the word was enclosed in quotes
+	Quoted bool
+	// Where the token begins
+	pos position
+	// The offset just past the end of the token
+	end int64
+}
+
+// The bare word that stands for a nil pointer
+const nil_word = "nil"
+
+// The size of the buffer that input is first read into
+const min_buffer_size = 4096
+
+// Reports whether the token is the bare word nil
+func (t *token) is_nil() bool {
+	return t.Type == token_word && !t.Quoted && string(t.Data) == nil_word
+}
+
+// Describes the token for use in error messages
+func (t token) String() string {
+	if t.Type == token_word {
+		return fmt.Sprintf("word %q", t.Data)
+	}
+	return t.Type.String()
+}
+
+func is_space(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t'
+}
+
+// Reads more input into the buffer. Bytes before the cursor are discarded, unless they are being captured.
+// Returns the error from the input (such as io.EOF) if nothing more could be read.
+func (decoder *Decoder) fill() (err error) {
+	if decoder.input_err != nil {
+		return decoder.input_err
+	}
+
+	discard := decoder.cursor
+	if decoder.capturing {
+		discard = min(discard, int(decoder.capture_start-decoder.base))
+	}
+	if discard > 0 {
+		n := copy(decoder.buffer, decoder.buffer[discard:])
+		decoder.buffer = decoder.buffer[:n]
+		decoder.cursor -= discard
+		decoder.base += int64(discard)
+	}
+
+	if len(decoder.buffer) == cap(decoder.buffer) {
+		grown := make([]byte, len(decoder.buffer), max(2*cap(decoder.buffer), min_buffer_size))
+		copy(grown, decoder.buffer)
+		decoder.buffer = grown
+	}
+
+	// Like bufio, give up on readers that keep returning nothing
+	for range 100 {
+		var n int
+		n, err = decoder.input.Read(decoder.buffer[len(decoder.buffer):cap(decoder.buffer)])
+		decoder.buffer = decoder.buffer[:len(decoder.buffer)+n]
+		if err != nil {
+			decoder.input_err = err
+			if n > 0 {
+				err = nil
+			}
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+
+	return io.ErrNoProgress
+}
+
+// Makes at least n unread bytes available in the buffer, unless the input ends first
+func (decoder *Decoder) ensure(n int) (err error) {
+	for len(decoder.buffer)-decoder.cursor < n {
+		if err = decoder.fill(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Consumes n bytes from the buffer, keeping track of the position
+func (decoder *Decoder) advance(n int) {
+	for _, c := range decoder.buffer[decoder.cursor : decoder.cursor+n] {
+		if c == '\n' {
+			decoder.pos.line++
+			decoder.pos.column = 1
+		} else if utf8.RuneStart(c) {
+			// Columns count runes, not bytes
+			decoder.pos.column++
+		}
+	}
+	decoder.cursor += n
+	decoder.pos.offset += int64(n)
+}
+
+// Consumes the rest of the buffer
+func (decoder *Decoder) advance_to_end() {
+	decoder.advance(len(decoder.buffer) - decoder.cursor)
+}
+
+// Returns a syntax error for input that ended in the middle of something
+func (decoder *Decoder) unexpected_eof(err error, what string) error {
+	if errors.Is(err, io.EOF) {
+		e := syntax_error(decoder.pos, "unexpected end of input in %s", what)
+		e.Err = io.ErrUnexpectedEOF
+		return e
+	}
+	return err
+}
+
+// Replaces the escape sequences in a quoted word, which are known to be valid
+func unescape(escaped []byte) []byte {
+	text := make([]byte, 0, len(escaped))
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c == '\\' {
+			i++
+			switch escaped[i] {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			default:
+				c = escaped[i]
+			}
+		}
+		text = append(text, c)
+	}
+	return text
+}
+
+func (decoder *Decoder) read_quoted_word() (word token, err error) {
+	var (
+		start = decoder.pos
+		// The length of the word so far, including the opening quote
+		n       = 1
+		escaped bool
+	)
+
+	for {
+		if err = decoder.ensure(n + 1); err != nil {
+			decoder.advance_to_end()
+			err = decoder.unexpected_eof(err, "quoted word")
+			return
+		}
+
+		c := decoder.buffer[decoder.cursor+n]
+		if c == '"' {
+			break
+		}
+
+		if c == '\\' {
+			if err = decoder.ensure(n + 2); err != nil {
+				decoder.advance_to_end()
+				err = decoder.unexpected_eof(err, "quoted word")
+				return
+			}
+
+			switch decoder.buffer[decoder.cursor+n+1] {
+			case 'n', 'r', 't', '\\', '"':
+			default:
+				decoder.advance(n + 1)
+				escaped_char, _ := utf8.DecodeRune(decoder.buffer[decoder.cursor:])
+				err = syntax_error(decoder.pos, "unknown escape sequence: \\%c", escaped_char)
+				return
+			}
+
+			escaped = true
+			n += 2
+			continue
+		}
+
+		n++
+	}
+
+	word = token{Type: token_word, Quoted: true, pos: start}
+	word.Data = decoder.buffer[decoder.cursor+1 : decoder.cursor+n]
+	if escaped {
+		// Only words with escape sequences are copied
+		word.Data = unescape(word.Data)
+	}
+
+	// The closing quote
+	decoder.advance(n + 1)
+	word.end = decoder.pos.offset
+	return
+}
+
+func (decoder *Decoder) read_word() (word token, err error) {
+	var (
+		start = decoder.pos
+		n     int
+	)
+
+	// whitespace, a new line or the end of input can terminate a word
+scan:
+	for {
+		for decoder.cursor+n < len(decoder.buffer) {
+			if is_space(decoder.buffer[decoder.cursor+n]) {
+				break scan
+			}
+			n++
+		}
+
+		if err = decoder.fill(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+				break
+			}
+			return
+		}
+	}
+
+	word = token{Type: token_word, Data: decoder.buffer[decoder.cursor : decoder.cursor+n], pos: start}
+	decoder.advance(n)
+	word.end = decoder.pos.offset
+	return
+}
+
+// Read a token from the input stream while not consuming it
+func (decoder *Decoder) peek_token() (t token, err error) {
+	return decoder.peek(false)
+}
+
+// Consume a token
+func (decoder *Decoder) next_token() (t token, err error) {
+	return decoder.next(false)
+}
+
+// Returns the next token without consuming it. Comment tokens are only returned if comments is true.
+func (decoder *Decoder) peek(comments bool) (t token, err error) {
+	if !comments {
+		decoder.drop_peeked_comment()
+	}
+
+	if decoder.has_peeked {
+		t = decoder.peeked
+		return
+	}
+
+	t, err = decoder.scan_token(comments)
+	if err != nil {
+		return
+	}
+
+	decoder.peeked = t
+	decoder.has_peeked = true
+	return
+}
+
+// Consumes the next token. Comment tokens are only returned if comments is true.
+func (decoder *Decoder) next(comments bool) (t token, err error) {
+	if !comments {
+		decoder.drop_peeked_comment()
+	}
+
+	if decoder.has_peeked {
+		t = decoder.peeked
+		decoder.has_peeked = false
+	} else {
+		t, err = decoder.scan_token(comments)
+		if err != nil {
+			return
+		}
+	}
+
+	decoder.last = t.pos
+	return
+}
+
+// A comment may have been peeked through the public token API
+func (decoder *Decoder) drop_peeked_comment() {
+	if decoder.has_peeked && decoder.peeked.Type == token_comment {
+		decoder.has_peeked = false
+	}
+}
+
+// Reads a token from the input stream. The end of input is only
+// acceptable between top-level values, so if a value is being decoded
+// an io.EOF is reported as a syntax error.
+func (decoder *Decoder) scan_token(comments bool) (t token, err error) {
+	t, err = decoder.read_token(comments)
+	if err != nil && decoder.in_value {
+		err = decoder.unexpected_eof(err, "value")
+	}
+	return
+}
+
+// Reads a comment, having peeked the opening characters.
+// The comment is returned as a token if comments is true.
+func (decoder *Decoder) read_comment(comments bool) (t token, ok bool, err error) {
+	var (
+		start = decoder.pos
+		// The length of the comment so far
+		n = 2
+		// The length of the comment's text, which excludes the carriage return before a new line
+		length int
+	)
+
+	if decoder.buffer[decoder.cursor+1] == '/' {
+		// A double-slash comment ends before the new line, or at the end of the input
+		for {
+			if err = decoder.ensure(n + 1); err != nil {
+				if !errors.Is(err, io.EOF) {
+					return
+				}
+				err = nil
+				break
+			}
+			if decoder.buffer[decoder.cursor+n] == '\n' {
+				break
+			}
+			n++
+		}
+
+		length = n
+		if decoder.buffer[decoder.cursor+n-1] == '\r' {
+			length--
+		}
+	} else {
+		for {
+			if err = decoder.ensure(n + 2); err != nil {
+				decoder.advance_to_end()
+				err = decoder.unexpected_eof(err, "block comment")
+				return
+			}
+			if decoder.buffer[decoder.cursor+n] == '*' && decoder.buffer[decoder.cursor+n+1] == '/' {
+				n += 2
+				break
+			}
+			n++
+		}
+
+		length = n
+	}
+
+	if comments {
+		t = token{Type: token_comment, Data: decoder.buffer[decoder.cursor : decoder.cursor+length], pos: start}
+		ok = true
+	}
+
+	decoder.advance(n)
+	t.end = decoder.pos.offset
+	return
+}
+
+func (decoder *Decoder) read_token(comments bool) (t token, err error) {
+	for {
+		if err = decoder.ensure(1); err != nil {
+			return
+		}
+
+		start := decoder.pos
+		c := decoder.buffer[decoder.cursor]
+
+		switch c {
+		case '/':
+			if err = decoder.ensure(2); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = syntax_error(start, "stray comment")
+				}
+				return
+			}
+			if second := decoder.buffer[decoder.cursor+1]; second != '/' && second != '*' {
+				err = syntax_error(start, "stray comment")
+				return
+			}
+
+			var ok bool
+			t, ok, err = decoder.read_comment(comments)
+			if err != nil || ok {
+				return
+			}
+		// whitespace
+		case ' ', '\t', '\r', '\n':
+			n := 1
+			for decoder.cursor+n < len(decoder.buffer) && is_space(decoder.buffer[decoder.cursor+n]) {
+				n++
+			}
+			decoder.advance(n)
+		case '[', ']', '{', '}':
+			decoder.advance(1)
+			t = token{Type: bracket_type(c), pos: start, end: decoder.pos.offset}
+			return
+		case '"':
+			return decoder.read_quoted_word()
+		default:
+			return decoder.read_word()
+		}
+	}
+}
+
+// Returns the token type of a bracket
+func bracket_type(c byte) token_type {
+	switch c {
+	case '[':
+		return token_open_table_header
+	case ']':
+		return token_close_table_header
+	case '{':
+		return token_open
+	default:
+		return token_close
+	}
+}
+
+func (decoder *Decoder) next_word() (word token, err error) {
+	word, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if word.Type != token_word {
+		err = syntax_error(word.pos, "expected a word, found %s", word)
+	}
+
+	return
+}
+
+// Consumes a token, returning a syntax error if it is not of the expected type
+func (decoder *Decoder) expect_token(expected token_type, what string) (t token, err error) {
+	t, err = decoder.next_token()
+	if err != nil {
+		return
+	}
+
+	if t.Type != expected {
+		err = syntax_error(t.pos, "expected %s %s, found %s", expected, what, t)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,140 @@
+package text_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Gophercraft/text"
+)
+
+func read_tokens(t *testing.T, in io.Reader) (tokens []text.Token) {
+	decoder := text.NewDecoder(in)
+	decoder.EmitComments = true
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestTokenizerSmallReads(t *testing.T) {
+	input := "[ ID \"Display\\tName\" ]\r\n// first row\r\n{ 1 /* a **/ \"Hog\\\"ger\" { \"\" \"été\" } }\n// last"
+
+	tokens := read_tokens(t, strings.NewReader(input))
+	if len(tokens) != 15 {
+		t.Fatal("wrong number of tokens", tokens)
+	}
+
+	// The input is read one byte at a time, so every token crosses the end of the buffer
+	if small := read_tokens(t, iotest.OneByteReader(strings.NewReader(input))); !reflect.DeepEqual(small, tokens) {
+		t.Fatal("tokens differ with small reads", small)
+	}
+
+	if tokens[11].Data != "été" || tokens[11].Column != 30 || tokens[11].End != 74 {
+		t.Fatal("wrong token", tokens[11])
+	}
+
+	var plugins raw_plugins
+	input = `{
+	Plugins {
+		{ Name chat Config { Port 8085 /* } */ Message "{" } }
+	}
+}`
+	if err := text.NewDecoder(iotest.OneByteReader(strings.NewReader(input))).Decode(&plugins); err != nil {
+		t.Fatal(err)
+	}
+	if string(plugins.Plugins[0].Config) != `{ Port 8085 /* } */ Message "{" }` {
+		t.Fatalf("wrong raw value %q", plugins.Plugins[0].Config)
+	}
+}
+
+type key_coords struct {
+	X, Y int32
+}
+
+type key_document struct {
+	Servers map[netip.Addr]error_effect
+	Zones   map[key_coords]error_effect
+}
+
+func TestTokenizerSmallReadsKeyPath(t *testing.T) {
+	// The buffer is refilled while each value is decoded, after its key has been read
+	cases := []struct {
+		input string
+		path  string
+	}{
+		{"{ Servers { 2001:db8:85a3::8a2e:370:7334 { Radius 300 } } }", `Servers["2001:db8:85a3::8a2e:370:7334"].Radius`},
+		{"{ Zones { { X 12 Y -4 } { Radius 300 } } }", `Zones["{12 -4}"].Radius`},
+	}
+
+	for _, c := range cases {
+		var document key_document
+		err := text.NewDecoder(iotest.OneByteReader(strings.NewReader(c.input))).Decode(&document)
+
+		var decode_error *text.DecodeError
+		if !errors.As(err, &decode_error) {
+			t.Fatal("expected a *DecodeError, got", err)
+		}
+		if decode_error.Path != c.path {
+			t.Fatal("wrong path", decode_error.Path)
+		}
+	}
+}
+
+type alloc_row struct {
+	ID      uint32
+	Quality uint8
+	Price   int64
+	Weight  float32
+	Enabled bool
+}
+
+func TestTokenizerAllocations(t *testing.T) {
+	var input bytes.Buffer
+	input.WriteString("[ ID Quality Price Weight Enabled ]\n")
+	for i := range 1000 {
+		input.WriteString("{ " + strconv.Itoa(i) + " 4 " + strconv.Itoa(i*25) + " 0.25 true }\n")
+	}
+	const tokens = 7 + 1000*7
+
+	var row alloc_row
+	allocs := testing.AllocsPerRun(10, func() {
+		decoder := text.NewDecoder(bytes.NewReader(input.Bytes()))
+		for {
+			err := decoder.Decode(&row)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	// The decoder, its buffer and the header are allocated once, but nothing is allocated per token
+	if per_token := allocs / tokens; per_token > 0.01 {
+		t.Fatal("too many allocations per token", per_token)
+	}
+
+	allocs = testing.AllocsPerRun(10, func() {
+		var raw text.RawValue
+		if err := text.Unmarshal([]byte("{"+strings.Repeat(` "quoted" bare { nested } // comment`+"\n", 1000)+"}"), &raw); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if per_token := allocs / 5000; per_token > 0.01 {
+		t.Fatal("too many allocations per token", per_token)
+	}
+}
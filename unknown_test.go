@@ -0,0 +1,159 @@
+package text_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Gophercraft/text"
+)
+
+type unknown_effect struct {
+	Radius uint8
+}
+
+type unknown_spell struct {
+	ID      uint32
+	Effects []unknown_effect
+}
+
+type unknown_spell_extra struct {
+	ID    uint32
+	Extra map[string]text.RawValue `text:",unknown"`
+}
+
+const unknown_input = `{
+	ID 12
+	Cooldown 1500
+	Effects {
+		{ Radius 4 Shape Cone }
+	}
+	Visual {
+		Model "fire.m2" // a newer field
+		Scale { 1 2 }
+	}
+}`
+
+func TestUnknownFieldsStrict(t *testing.T) {
+	var spell unknown_spell
+	err := text.Unmarshal([]byte(unknown_input), &spell)
+
+	var unknown_error *text.UnknownFieldError
+	if !errors.As(err, &unknown_error) {
+		t.Fatal("expected an *UnknownFieldError, got", err)
+	}
+
+	paths := []string{"Cooldown", "Effects[0].Shape", "Visual"}
+	if len(unknown_error.Fields) != len(paths) {
+		t.Fatal("wrong number of unknown fields", unknown_error.Fields)
+	}
+	for i, path := range paths {
+		if unknown_error.Fields[i].Path != path {
+			t.Fatal("wrong path", unknown_error.Fields[i].Path, "expected", path)
+		}
+	}
+
+	if unknown_error.Fields[1].Line != 5 || unknown_error.Fields[1].Column != 14 {
+		t.Fatal("wrong position", unknown_error.Fields[1].Line, unknown_error.Fields[1].Column)
+	}
+
+	// The rest of the value is still decoded
+	if spell.ID != 12 || len(spell.Effects) != 1 || spell.Effects[0].Radius != 4 {
+		t.Fatal("known fields were not decoded", spell)
+	}
+}
+
+func TestUnknownFieldsSkip(t *testing.T) {
+	decoder := text.NewDecoder(strings.NewReader(unknown_input))
+	decoder.SkipUnknownFields = true
+
+	var spell unknown_spell
+	if err := decoder.Decode(&spell); err != nil {
+		t.Fatal(err)
+	}
+
+	if spell.ID != 12 || len(spell.Effects) != 1 || spell.Effects[0].Radius != 4 {
+		t.Fatal("known fields were not decoded", spell)
+	}
+}
+
+func TestUnknownFieldsCatchAll(t *testing.T) {
+	var spell unknown_spell_extra
+	if err := text.Unmarshal([]byte(unknown_input), &spell); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Cooldown": "1500",
+		"Effects":  "{\n\t\t{ Radius 4 Shape Cone }\n\t}",
+		"Visual":   "{\n\t\tModel \"fire.m2\" // a newer field\n\t\tScale { 1 2 }\n\t}",
+	}
+
+	if spell.ID != 12 || len(spell.Extra) != len(expected) {
+		t.Fatal("wrong value", spell)
+	}
+	for key, value := range expected {
+		if string(spell.Extra[key]) != value {
+			t.Fatalf("wrong raw value for %s: %q", key, spell.Extra[key])
+		}
+	}
+}
+
+func TestUnknownColumns(t *testing.T) {
+	input := `[ ID Cooldown Name ]
+{ 1 1500 "Fire Ball" }
+{ 2 { 0 nil } Frostbolt }
+`
+
+	decoder := text.NewDecoder(strings.NewReader(input))
+
+	var spell unknown_spell
+	err := decoder.Decode(&spell)
+	var unknown_error *text.UnknownFieldError
+	if !errors.As(err, &unknown_error) {
+		t.Fatal("expected an *UnknownFieldError, got", err)
+	}
+	if len(unknown_error.Fields) != 2 || unknown_error.Fields[0].Path != "Cooldown" || unknown_error.Fields[1].Path != "Name" {
+		t.Fatal("wrong unknown fields", unknown_error.Fields)
+	}
+	if spell.ID != 1 {
+		t.Fatal("wrong value", spell)
+	}
+
+	// The decoder can carry on with the next row
+	var extra unknown_spell_extra
+	if err = decoder.Decode(&extra); err != nil {
+		t.Fatal(err)
+	}
+	if extra.ID != 2 || string(extra.Extra["Cooldown"]) != "{ 0 nil }" || string(extra.Extra["Name"]) != "Frostbolt" {
+		t.Fatal("wrong value", extra)
+	}
+}
+
+// An unknown key holding an interface value, written after its registered type name
+const unknown_typed_input = `{
+	ID 12
+	Effect AuraEffect { Radius 5 }
+	Cooldown 1500
+}`
+
+func TestUnknownTypedFields(t *testing.T) {
+	decoder := text.NewDecoder(strings.NewReader(unknown_typed_input))
+	decoder.SkipUnknownFields = true
+
+	var spell unknown_spell
+	if err := decoder.Decode(&spell); err != nil {
+		t.Fatal(err)
+	}
+	if spell.ID != 12 {
+		t.Fatal("known fields were not decoded", spell)
+	}
+
+	var extra unknown_spell_extra
+	if err := text.Unmarshal([]byte(unknown_typed_input), &extra); err != nil {
+		t.Fatal(err)
+	}
+	if extra.ID != 12 || string(extra.Extra["Effect"]) != "AuraEffect { Radius 5 }" || string(extra.Extra["Cooldown"]) != "1500" {
+		t.Fatal("wrong value", extra)
+	}
+}
@@ -0,0 +1,90 @@
+package text
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var (
+	text_marshaler_type   = reflect.TypeFor[encoding.TextMarshaler]()
+	text_unmarshaler_type = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Reports whether t implements an interface, with either value or pointer receivers
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+// Values are words if they implement the Word interface, otherwise both encoding.TextMarshaler and
+// encoding.TextUnmarshaler, so that every word that is encoded can be decoded again
+func is_word(t reflect.Type) bool {
+	return implements(t, word_type) || (implements(t, text_marshaler_type) && implements(t, text_unmarshaler_type))
+}
+
+func can_encode_word(field reflect.Value) bool {
+	return get_type_info(field.Type()).word
+}
+
+func can_decode_word(field reflect.Value) bool {
+	return get_type_info(field.Type()).word
+}
+
+// Returns a value that can be used with pointer receiver methods
+func addressable(value reflect.Value) reflect.Value {
+	if value.CanAddr() {
+		return value.Addr()
+	}
+	// This value is not addressable, but needs to be used as a pointer receiver. This is a bit of a problem.
+	// This happens when map key values are used
+	// The best we can do is dupe the value.
+	new_alloc := reflect.New(value.Type())
+	new_alloc.Elem().Set(value)
+	return new_alloc
+}
+
+// Returns the word that a value encodes to
+func word_string(value reflect.Value) (str string, err error) {
+	t := value.Type()
+
+	switch {
+	// Maps, etc already act like pointers
+	case t.Implements(word_type):
+		return value.Interface().(Word).EncodeWord()
+	// Use pointer receiver methods
+	case reflect.PointerTo(t).Implements(word_type):
+		return addressable(value).Interface().(Word).EncodeWord()
+	case t.Implements(text_marshaler_type):
+		var b []byte
+		b, err = value.Interface().(encoding.TextMarshaler).MarshalText()
+		str = string(b)
+	case reflect.PointerTo(t).Implements(text_marshaler_type):
+		var b []byte
+		b, err = addressable(value).Interface().(encoding.TextMarshaler).MarshalText()
+		str = string(b)
+	}
+	return
+}
+
+// Consumes a word and decodes it into a value using its Word or encoding.TextUnmarshaler methods
+func (decoder *Decoder) decode_word(value reflect.Value) (err error) {
+	var word_token token
+	word_token, err = decoder.next_word()
+	if err != nil {
+		return
+	}
+
+	initialize_value(value)
+
+	t := value.Type()
+
+	switch {
+	case reflect.PointerTo(t).Implements(word_type):
+		return value.Addr().Interface().(Word).DecodeWord(string(word_token.Data))
+	case t.Implements(word_type):
+		return value.Interface().(Word).DecodeWord(string(word_token.Data))
+	case reflect.PointerTo(t).Implements(text_unmarshaler_type):
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(word_token.Data)
+	default:
+		return value.Interface().(encoding.TextUnmarshaler).UnmarshalText(word_token.Data)
+	}
+}
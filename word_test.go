@@ -0,0 +1,170 @@
+package text_test
+
+import (
+	"bytes"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gophercraft/text"
+)
+
+// Implements both Word and encoding.TextMarshaler. Word should take precedence.
+type word_precedence uint32
+
+func (w word_precedence) EncodeWord() (string, error) {
+	return "word-" + strconv.FormatUint(uint64(w), 10), nil
+}
+
+func (w *word_precedence) DecodeWord(data string) error {
+	u, err := strconv.ParseUint(strings.TrimPrefix(data, "word-"), 10, 32)
+	*w = word_precedence(u)
+	return err
+}
+
+func (w word_precedence) MarshalText() ([]byte, error) {
+	return []byte("marshaler"), nil
+}
+
+func (w *word_precedence) UnmarshalText(data []byte) error {
+	*w = 0
+	return nil
+}
+
+type text_marshaler_record struct {
+	Address netip.Addr
+	Amount  big.Int
+	Time    *time.Time
+	Owners  map[netip.Addr]string
+	Flags   word_precedence
+}
+
+func TestTextMarshaler(t *testing.T) {
+	when := time.Date(2004, 11, 23, 0, 0, 0, 0, time.UTC)
+	record := text_marshaler_record{
+		Address: netip.MustParseAddr("10.0.0.1"),
+		Time:    &when,
+		Owners: map[netip.Addr]string{
+			netip.MustParseAddr("192.168.0.2"): "b",
+			netip.MustParseAddr("192.168.0.1"): "a",
+		},
+		Flags: 7,
+	}
+	record.Amount.SetString("123456789012345678901234567890", 10)
+
+	data, err := text.Marshal(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+	Address 10.0.0.1
+	Amount 123456789012345678901234567890
+	Time 2004-11-23T00:00:00Z
+	Owners
+	{
+		192.168.0.1 a
+		192.168.0.2 b
+	}
+	Flags word-7
+}
+`
+	if string(data) != expected {
+		t.Fatal(string(data), "should have been equal to", expected)
+	}
+
+	var decoded text_marshaler_record
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Amount.Cmp(&record.Amount) != 0 {
+		t.Fatal("wrong amount", decoded.Amount.String())
+	}
+	decoded.Amount = record.Amount
+	if !reflect.DeepEqual(record, decoded) {
+		t.Fatal("got back incorrect record", decoded)
+	}
+}
+
+func TestTextMarshalerTable(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := text.NewEncoder(&buf)
+	encoder.Indent = " "
+	encoder.Tabular = true
+
+	record := text_marshaler_record{
+		Address: netip.MustParseAddr("::1"),
+		Owners:  map[netip.Addr]string{netip.MustParseAddr("::2"): "two"},
+		Flags:   3,
+	}
+	if err := encoder.Encode(&record); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[ Address Amount Time Owners Flags ]\n{ ::1 0 nil { ::2 two } word-3 }\n"
+	if buf.String() != expected {
+		t.Fatal(buf.String(), "should have been equal to", expected)
+	}
+
+	var decoded text_marshaler_record
+	if err := text.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(record.Owners, decoded.Owners) || decoded.Address != record.Address || decoded.Flags != 3 {
+		t.Fatal("got back incorrect record", decoded)
+	}
+}
+
+// Implements only encoding.TextMarshaler
+type marshal_only struct {
+	Value uint32
+}
+
+func (m marshal_only) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(m.Value), 10)), nil
+}
+
+// Implements only encoding.TextUnmarshaler
+type unmarshal_only struct {
+	Value uint32
+}
+
+func (u *unmarshal_only) UnmarshalText(data []byte) error {
+	v, err := strconv.ParseUint(string(data), 10, 32)
+	u.Value = uint32(v)
+	return err
+}
+
+type one_sided_record struct {
+	Marshal   marshal_only
+	Unmarshal unmarshal_only
+}
+
+func TestOneSidedTextMarshaler(t *testing.T) {
+	// Neither type is a word, since it could not be read back, so both are encoded field by field
+	record := one_sided_record{Marshal: marshal_only{Value: 1}, Unmarshal: unmarshal_only{Value: 2}}
+	data, err := text.Marshal(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\n\tMarshal\n\t{\n\t\tValue 1\n\t}\n\tUnmarshal\n\t{\n\t\tValue 2\n\t}\n}\n" {
+		t.Fatalf("wrong encoding\n%s", data)
+	}
+
+	var decoded one_sided_record
+	if err = text.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != record {
+		t.Fatal("got back incorrect record", decoded)
+	}
+}